@@ -2,18 +2,36 @@ package cmd
 
 // Standard library on top, third-party packages below.
 import (
+	"context"
 	"log"
 	"math"
 	"net/http"
+	"os"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/sentinelvote/backend/internal/session"
 )
 
 // Run is called by main.go and is effectively the entrypoint of the application.
+// "backend recount ..." runs the recount command instead of the server, see Recount.
 func Run() error {
+	if len(os.Args) > 1 && os.Args[1] == "recount" {
+		return Recount(os.Args[2:])
+	}
+
 	var flags = ParseCLI()
 	s := Server{}
 
+	// Set up session tokens.
+	sessions, err := session.NewIssuer(flags.SessionSecret, flags.SessionTTL)
+	if err != nil {
+		return err
+	}
+	if flags.SessionSecret == "" {
+		log.Println("No session secret given, tokens will not survive a restart")
+	}
+	s.Sessions = sessions
+
 	// Set up the router.
 	s.Router = chi.NewRouter()
 	s.middleware()
@@ -21,6 +39,7 @@ func Run() error {
 
 	// Set up the database.
 	s.URI = flags.URI
+	s.Resume = flags.Resume
 	s.TotalUsers = flags.TotalUsers
 	s.Schema = flags.Schema
 	s.PoolSize = int(math.Ceil(float64(s.TotalUsers) * .75))
@@ -29,9 +48,22 @@ func Run() error {
 	} else if s.PoolSize <= 3 {
 		s.PoolSize = 4
 	}
+	if err := s.ledger(flags); err != nil {
+		return err
+	}
 	if err := s.database(s.URI); err != nil {
 		return err
 	}
+	if err := s.prepareJobs(); err != nil {
+		return err
+	}
+
+	// Open and close voting windows in the background.
+	s.reschedule = make(chan struct{}, 1)
+	go s.scheduler(context.Background())
+
+	// Mark voters who have voted in batches, see markVoted.
+	go s.participationRecorder(context.Background())
 
 	log.Println("Starting server on :8080")
 	return http.ListenAndServe(":8080", s.Router)
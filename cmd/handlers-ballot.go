@@ -0,0 +1,178 @@
+package cmd
+
+// Standard library on top, application and third-party packages below.
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/goccy/go-json"
+	"github.com/sentinelvote/backend/internal/ballot"
+	"github.com/sentinelvote/backend/internal/election"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// This file contains the handlers managing the candidates on the ballot of an election.
+// See internal/ballot for the canonical encoding of a ballot.
+
+// handleGetBallot lists the candidates a voter may choose between, in ballot order.
+// The optional constituency query parameter filters out candidates standing elsewhere.
+func (s *Server) handleGetBallot() http.HandlerFunc {
+	type response struct {
+		Election     int64              `json:"election"`
+		Constituency string             `json:"constituency,omitempty"`
+		Candidates   []ballot.Candidate `json:"candidates"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn := s.Database.Get(r.Context())
+		defer s.Database.Put(conn)
+		e, _ := election.FromContext(r.Context())
+
+		candidates, err := ballot.ListCandidates(conn, e.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		constituency := strings.ToUpper(r.URL.Query().Get("constituency"))
+		res := response{Election: e.ID, Constituency: constituency, Candidates: []ballot.Candidate{}}
+		for _, c := range candidates {
+			if constituency == "" || c.Constituency == "" || c.Constituency == constituency {
+				res.Candidates = append(res.Candidates, c)
+			}
+		}
+
+		jsonResponse, err := json.Marshal(res)
+		if err != nil {
+			http.Error(w, "Error converting response to JSON", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(&w, jsonResponse)
+	}
+}
+
+// handleAdminCreateCandidate adds a candidate to the ballot of the election in the request context.
+func (s *Server) handleAdminCreateCandidate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		candidate, ok := decodeCandidate(w, r)
+		if !ok {
+			return
+		}
+
+		conn := s.Database.Get(r.Context())
+		defer s.Database.Put(conn)
+		e, _ := election.FromContext(r.Context())
+
+		err := sqlitex.Execute(conn, `
+			INSERT INTO candidates (election_id, constituency, name, party, position) VALUES (?, ?, ?, ?, ?);`,
+			&sqlitex.ExecOptions{
+				Args: []any{e.ID, candidate.Constituency, candidate.Name, candidate.Party, candidate.Position},
+			})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		candidate.ID = conn.LastInsertRowID()
+
+		jsonResponse, err := json.Marshal(candidate)
+		if err != nil {
+			http.Error(w, "Error converting response to JSON", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(&w, jsonResponse)
+	}
+}
+
+// handleAdminUpdateCandidate replaces a candidate on the ballot of the election in the request context.
+func (s *Server) handleAdminUpdateCandidate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "candidateID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid candidate id", http.StatusBadRequest)
+			return
+		}
+		candidate, ok := decodeCandidate(w, r)
+		if !ok {
+			return
+		}
+
+		conn := s.Database.Get(r.Context())
+		defer s.Database.Put(conn)
+		e, _ := election.FromContext(r.Context())
+
+		err = sqlitex.Execute(conn, `
+			UPDATE candidates SET constituency = ?, name = ?, party = ?, position = ?
+			WHERE id = ? AND election_id = ?;`,
+			&sqlitex.ExecOptions{
+				Args: []any{candidate.Constituency, candidate.Name, candidate.Party, candidate.Position, id, e.ID},
+			})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if conn.Changes() == 0 {
+			http.Error(w, "Candidate not found", http.StatusNotFound)
+			return
+		}
+		candidate.ID = id
+
+		jsonResponse, err := json.Marshal(candidate)
+		if err != nil {
+			http.Error(w, "Error converting response to JSON", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(&w, jsonResponse)
+	}
+}
+
+// handleAdminDeleteCandidate removes a candidate from the ballot of the election in the request context.
+func (s *Server) handleAdminDeleteCandidate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "candidateID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid candidate id", http.StatusBadRequest)
+			return
+		}
+
+		conn := s.Database.Get(r.Context())
+		defer s.Database.Put(conn)
+		e, _ := election.FromContext(r.Context())
+
+		err = sqlitex.Execute(conn, `DELETE FROM candidates WHERE id = ? AND election_id = ?;`, &sqlitex.ExecOptions{
+			Args: []any{id, e.ID},
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if conn.Changes() == 0 {
+			http.Error(w, "Candidate not found", http.StatusNotFound)
+			return
+		}
+		respondPlainText(&w, "Successfully deleted the candidate")
+	}
+}
+
+// decodeCandidate reads and validates a candidate from the request body.
+// It writes the error to the response if the candidate is invalid.
+func decodeCandidate(w http.ResponseWriter, r *http.Request) (ballot.Candidate, bool) {
+	if !isHeaderJSON(w, r) {
+		return ballot.Candidate{}, false
+	}
+	defer bodyClose(r.Body)
+
+	var candidate ballot.Candidate
+	if err := json.NewDecoder(r.Body).Decode(&candidate); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return ballot.Candidate{}, false
+	}
+	candidate.Name = strings.TrimSpace(candidate.Name)
+	candidate.Constituency = strings.ToUpper(strings.TrimSpace(candidate.Constituency))
+	if candidate.Name == "" {
+		http.Error(w, "Missing name parameter", http.StatusBadRequest)
+		return ballot.Candidate{}, false
+	}
+	return candidate, true
+}
@@ -0,0 +1,348 @@
+package cmd
+
+// Standard library on top, application and third-party packages below.
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/goccy/go-json"
+	"github.com/sentinelvote/backend/internal/ballot"
+	"github.com/sentinelvote/backend/internal/bulletin"
+	"github.com/sentinelvote/backend/internal/election"
+	"github.com/sentinelvote/backend/internal/foldpub"
+	"github.com/sentinelvote/backend/internal/tally"
+	"github.com/zbohm/lirisi/client"
+	"github.com/zbohm/lirisi/ring"
+)
+
+// This file contains the handlers of the bulletin, the signed ballots cast through the server.
+// See internal/bulletin.
+
+// handleCastBallot verifies a signed ballot against the current ring of its constituency, submits it
+// to the ledger, records it on the bulletin, and returns the voter's receipt.
+// It needs no authentication, as the ring signature already proves the ballot comes from a voter,
+// without revealing which one. Sending the same signed ballot again returns the same receipt,
+// and submits nothing more to the ledger, see hashLocks.
+func (s *Server) handleCastBallot() http.HandlerFunc {
+	type request struct {
+		Message   string `json:"message"`
+		Signature string `json:"signature"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isHeaderJSON(w, r) {
+			return
+		}
+		defer bodyClose(r.Body)
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Error parsing JSON request body", http.StatusBadRequest)
+			return
+		}
+
+		// Validate required parameters.
+		if req.Message == "" {
+			http.Error(w, "Missing message parameter", http.StatusBadRequest)
+			return
+		}
+		if req.Signature == "" {
+			http.Error(w, "Missing signature parameter", http.StatusBadRequest)
+			return
+		}
+		choice, err := ballot.Decode([]byte(req.Message))
+		if err != nil {
+			http.Error(w, "The message is not a canonically encoded ballot", http.StatusBadRequest)
+			return
+		}
+
+		e, _ := election.FromContext(r.Context())
+		hash := bulletin.Hash([]byte(req.Message), []byte(req.Signature))
+		conn := s.Database.Get(r.Context())
+		entry, found, err := bulletin.Get(conn, e.ID, hash)
+		var candidates []ballot.Candidate
+		var current foldpub.Snapshot
+		published := false
+		if err == nil && !found {
+			candidates, err = ballot.ListCandidates(conn, e.ID)
+		}
+		if err == nil && !found {
+			current, published, err = currentRing(conn, e, choice.Constituency)
+		}
+		s.Database.Put(conn)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if found {
+			respondReceipt(&w, entry)
+			return
+		}
+		if !ballot.Eligible(e.ID, candidates)(choice) {
+			http.Error(w, "The candidate does not stand in this election and constituency", http.StatusBadRequest)
+			return
+		}
+		if !published {
+			http.Error(w, "No ring has been published for this ballot", http.StatusConflict)
+			return
+		}
+
+		status := client.VerifySignature([]byte(current.FoldedPublicKeys), []byte(req.Signature), []byte(req.Message), election.CaseIdentifier(e.ID))
+		if status != ring.Success {
+			http.Error(w, "Invalid signature: "+ring.ErrorMessages[status], http.StatusBadRequest)
+			return
+		}
+		status, keyImage := client.SignatureKeyImage([]byte(req.Signature), false)
+		if status != ring.Success {
+			http.Error(w, "Invalid signature: "+ring.ErrorMessages[status], http.StatusBadRequest)
+			return
+		}
+
+		// Submit the ballot only once, even if it is sent again before it is recorded: the same ballot waits
+		// for the submission in progress, and then finds it on the bulletin unless that submission failed.
+		unlock, err := s.casting.lock(r.Context(), hash)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		defer unlock()
+		conn = s.Database.Get(r.Context())
+		entry, found, err = bulletin.Get(conn, e.ID, hash)
+		s.Database.Put(conn)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if found {
+			respondReceipt(&w, entry)
+			return
+		}
+
+		// Once the ledger has accepted the ballot, it must be recorded, even if the voter has gone.
+		ctx := context.WithoutCancel(r.Context())
+		result, err := s.Ledger.SubmitBallot(ctx, e.ID, tally.Ballot{
+			Message:   []byte(req.Message),
+			Signature: []byte(req.Signature),
+		})
+		if err != nil {
+			respondLedgerError(&w, err)
+			return
+		}
+
+		conn = s.Database.Get(ctx)
+		defer s.Database.Put(conn)
+		entry, err = bulletin.Record(conn, bulletin.Entry{
+			ElectionID:   e.ID,
+			Hash:         hash,
+			Constituency: current.Constituency,
+			Message:      req.Message,
+			Signature:    req.Signature,
+			KeyImage:     string(keyImage),
+			Ring:         current.Hash,
+			TxID:         result.TxID,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondReceipt(&w, entry)
+	}
+}
+
+// handleGetReceipt looks up a receipt by the hash of its ballot, see bulletin.Hash. It reports whether the ballot
+// was recorded on the bulletin and, once the results are published, whether it counted in the tally.
+// It never returns the ballot's message, nor anything about who cast it.
+func (s *Server) handleGetReceipt() http.HandlerFunc {
+	type response struct {
+		bulletin.Receipt
+		Recorded bool          `json:"recorded"` // On the bulletin, i.e. cast through POST /ballots
+		Tallied  bool          `json:"tallied"`  // In the published tally
+		Outcome  tally.Outcome `json:"outcome,omitempty"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		hash := strings.ToLower(chi.URLParam(r, "hash"))
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*sha256.Size {
+			http.Error(w, "Invalid receipt hash", http.StatusBadRequest)
+			return
+		}
+
+		conn := s.Database.Get(r.Context())
+		defer s.Database.Put(conn)
+		e, _ := election.FromContext(r.Context())
+
+		entry, recorded, err := bulletin.Get(conn, e.ID, hash)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res := response{Receipt: entry.Receipt(), Recorded: recorded}
+
+		// Ballots sent to the ledger without going through the server are only known to the tally.
+		if e.Status == election.Published {
+			b, tallied, err := tallyOutcome(conn, e.ID, hash)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if tallied && !recorded {
+				res.Receipt = bulletin.Receipt{Hash: hash, ElectionID: e.ID, KeyImage: b.KeyImage, TxID: b.Reference}
+			}
+			res.Tallied, res.Outcome = tallied, b.Outcome
+		}
+		if !res.Recorded && !res.Tallied {
+			http.Error(w, "Receipt not found", http.StatusNotFound)
+			return
+		}
+
+		jsonResponse, err := json.Marshal(res)
+		if err != nil {
+			http.Error(w, "Error converting response to JSON", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(&w, jsonResponse)
+	}
+}
+
+// handleGetBulletin returns a page of the bulletin of the election in the request context in JSON,
+// oldest entry first. Add ?after= with the previous page's next to get the following page,
+// and ?limit= to change the page size. next is omitted on the last page.
+func (s *Server) handleGetBulletin() http.HandlerFunc {
+	const defaultLimit, maxLimit = 100, 1000
+	type response struct {
+		Entries []bulletin.Entry `json:"entries"`
+		Next    int64            `json:"next,omitempty"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		after, limit := int64(0), defaultLimit
+		var err error
+		if param := r.URL.Query().Get("after"); param != "" {
+			if after, err = strconv.ParseInt(param, 10, 64); err != nil || after < 0 {
+				http.Error(w, "Invalid after parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		if param := r.URL.Query().Get("limit"); param != "" {
+			if limit, err = strconv.Atoi(param); err != nil || limit < 1 || limit > maxLimit {
+				http.Error(w, "Invalid limit parameter, use 1 to "+strconv.Itoa(maxLimit), http.StatusBadRequest)
+				return
+			}
+		}
+
+		conn := s.Database.Get(r.Context())
+		defer s.Database.Put(conn)
+		e, _ := election.FromContext(r.Context())
+
+		// Ask for one more entry than needed, to know whether there is a next page.
+		entries, err := bulletin.List(conn, e.ID, after, limit+1)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res := response{Entries: entries}
+		if len(entries) > limit {
+			res.Entries = entries[:limit]
+			res.Next = res.Entries[limit-1].ID
+		}
+
+		jsonResponse, err := json.Marshal(res)
+		if err != nil {
+			http.Error(w, "Error converting response to JSON", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(&w, jsonResponse)
+	}
+}
+
+// handleGetBulletinArchive downloads the whole bulletin of the election in the request context,
+// as JSON Lines: one entry per line, oldest first. It is read in pages, so that a slow download
+// does not hold on to a database connection.
+func (s *Server) handleGetBulletinArchive() http.HandlerFunc {
+	const pageSize = 1000
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		e, _ := election.FromContext(r.Context())
+		w.Header().Set("Content-Type", "application/jsonl")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="bulletin-%d.jsonl"`, e.ID))
+
+		encoder := json.NewEncoder(w)
+		after := int64(0)
+		for {
+			conn := s.Database.Get(r.Context())
+			if conn == nil {
+				return
+			}
+			entries, err := bulletin.List(conn, e.ID, after, pageSize)
+			s.Database.Put(conn)
+			if err != nil {
+				// Headers may already be sent, so the error can only end the archive early.
+				log.Println("Error reading the bulletin: " + err.Error())
+				return
+			}
+			for _, entry := range entries {
+				if err := encoder.Encode(entry); err != nil {
+					return
+				}
+			}
+			if len(entries) < pageSize {
+				return
+			}
+			after = entries[len(entries)-1].ID
+		}
+	}
+}
+
+// hashLocks locks ballots by their hash, see bulletin.Hash, so that each is submitted to the ledger once.
+// A lock only lasts for the submission, and is not shared with other servers.
+type hashLocks struct {
+	mu   sync.Mutex
+	held map[string]chan struct{} // Closed when the lock is released
+}
+
+// lock waits until no other request holds the lock of hash, or until ctx is done, and takes it.
+// The lock is held until unlock is called.
+func (l *hashLocks) lock(ctx context.Context, hash string) (unlock func(), err error) {
+	for {
+		l.mu.Lock()
+		released, held := l.held[hash]
+		if !held {
+			if l.held == nil {
+				l.held = map[string]chan struct{}{}
+			}
+			released = make(chan struct{})
+			l.held[hash] = released
+			l.mu.Unlock()
+			return func() {
+				l.mu.Lock()
+				delete(l.held, hash)
+				l.mu.Unlock()
+				close(released)
+			}, nil
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// respondReceipt writes the receipt of a bulletin entry in JSON.
+func respondReceipt(w *http.ResponseWriter, entry bulletin.Entry) {
+	jsonResponse, err := json.Marshal(entry.Receipt())
+	if err != nil {
+		http.Error(*w, "Error converting response to JSON", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, jsonResponse)
+}
@@ -0,0 +1,240 @@
+package cmd
+
+// Standard library on top, application and third-party packages below.
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/sentinelvote/backend/internal/ballot"
+	"github.com/sentinelvote/backend/internal/bulletin"
+	"github.com/sentinelvote/backend/internal/election"
+	"github.com/sentinelvote/backend/internal/tally"
+	"github.com/zbohm/lirisi/client"
+	"github.com/zbohm/lirisi/ring"
+)
+
+// signBallot returns a ballot of a voter, encoded and signed with the current ring through the server.
+func signBallot(t *testing.T, s *Server, email string, b ballot.Ballot) (message string, signature string) {
+	t.Helper()
+	var key struct {
+		PrivateKey string `json:"privateKey"`
+	}
+	decode(t, do(t, s, http.MethodPost, "/voter/private-key", login(t, s, email), map[string]any{}), http.StatusOK, &key)
+	var res struct {
+		Signature string `json:"signature"`
+	}
+	message = string(b.Encode())
+	decode(t, do(t, s, http.MethodPost, "/lrs/sign", "", map[string]any{"privateKeyContent": key.PrivateKey, "message": message}), http.StatusOK, &res)
+	return message, res.Signature
+}
+
+// castBallot signs a ballot of a voter and casts it, returning its receipt.
+func castBallot(t *testing.T, s *Server, email string, b ballot.Ballot) bulletin.Receipt {
+	t.Helper()
+	message, signature := signBallot(t, s, email, b)
+	var receipt bulletin.Receipt
+	decode(t, do(t, s, http.MethodPost, "/ballots", "", map[string]any{"message": message, "signature": signature}), http.StatusOK, &receipt)
+	return receipt
+}
+
+func TestCastBallot(t *testing.T) {
+	s := newTestServer(t, "simulation-full", 4)
+	choice := ballot.Ballot{Election: election.DefaultID, Constituency: "BEDOK", Candidate: 1}
+	message, signature := signBallot(t, s, "user1@sentinelvote.tech", choice)
+
+	// A signature with a ring the voter is in, but which is not the published one.
+	var key struct {
+		PrivateKey string `json:"privateKey"`
+	}
+	decode(t, do(t, s, http.MethodPost, "/voter/private-key", login(t, s, "user2@sentinelvote.tech"), map[string]any{}), http.StatusOK, &key)
+	_, publicKey := client.DerivePublicKey([]byte(key.PrivateKey), "PEM")
+	_, otherKey := client.GeneratePrivateKey("prime256v1", "PEM")
+	_, otherPublicKey := client.DerivePublicKey(otherKey, "PEM")
+	_, otherRing := client.FoldPublicKeys([][]byte{publicKey, otherPublicKey}, "sha3-256", "PEM", "hashes")
+	status, otherSignature := client.CreateSignature(otherRing, []byte(key.PrivateKey), []byte(message), election.CaseIdentifier(election.DefaultID), "PEM")
+	if status != ring.Success {
+		t.Fatal(ring.ErrorMessages[status])
+	}
+
+	ineligible := choice
+	ineligible.Candidate = 3
+	tests := []struct {
+		name      string
+		message   string
+		signature string
+		status    int
+	}{
+		{"missing signature", message, "", http.StatusBadRequest},
+		{"non-canonical message", message + " ", signature, http.StatusBadRequest},
+		{"ineligible candidate", string(ineligible.Encode()), signature, http.StatusBadRequest},
+		{"tampered message", strings.Replace(message, `"candidate":1`, `"candidate":2`, 1), signature, http.StatusBadRequest},
+		{"malformed signature", message, "signature", http.StatusBadRequest},
+		{"signature with another ring", message, string(otherSignature), http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if w := do(t, s, http.MethodPost, "/ballots", "", map[string]any{"message": test.message, "signature": test.signature}); w.Code != test.status {
+				t.Errorf("got status %d, want %d: %s", w.Code, test.status, w.Body.String())
+			}
+		})
+	}
+
+	// The same ballot, sent several times at once, is submitted to the ledger once and has one receipt.
+	receipts := make([]bulletin.Receipt, 4)
+	var wg sync.WaitGroup
+	for i := range receipts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := do(t, s, http.MethodPost, "/ballots", "", map[string]any{"message": message, "signature": signature})
+			if w.Code != http.StatusOK {
+				t.Errorf("got status %d: %s", w.Code, w.Body.String())
+			}
+			_ = json.Unmarshal(w.Body.Bytes(), &receipts[i])
+		}(i)
+	}
+	wg.Wait()
+	for _, receipt := range receipts {
+		if receipt != receipts[0] {
+			t.Errorf("got receipts %+v and %+v for the same ballot", receipts[0], receipt)
+		}
+	}
+	if receipts[0].Hash != bulletin.Hash([]byte(message), []byte(signature)) || receipts[0].KeyImage == "" || receipts[0].Ring == "" {
+		t.Errorf("got receipt %+v", receipts[0])
+	}
+	ballots, err := s.Ledger.ListBallots(context.Background(), election.DefaultID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ballots) != 1 {
+		t.Errorf("got %d ballots on the ledger, want 1", len(ballots))
+	}
+}
+
+func TestGetReceipt(t *testing.T) {
+	s := newTestServer(t, "simulation-full", 4)
+	admin := login(t, s, "admin@sentinelvote.tech")
+	first := castBallot(t, s, "user1@sentinelvote.tech", ballot.Ballot{Election: election.DefaultID, Constituency: "BEDOK", Candidate: 1})
+	second := castBallot(t, s, "user1@sentinelvote.tech", ballot.Ballot{Election: election.DefaultID, Constituency: "BEDOK", Candidate: 2})
+
+	// A ballot sent to the ledger without going through the server.
+	message, signature := signBallot(t, s, "user2@sentinelvote.tech", ballot.Ballot{Election: election.DefaultID, Constituency: "BEDOK", Candidate: 2})
+	if _, err := s.Ledger.SubmitBallot(context.Background(), election.DefaultID, tally.Ballot{Message: []byte(message), Signature: []byte(signature)}); err != nil {
+		t.Fatal(err)
+	}
+	direct := bulletin.Hash([]byte(message), []byte(signature))
+
+	type response struct {
+		bulletin.Receipt
+		Recorded bool          `json:"recorded"`
+		Tallied  bool          `json:"tallied"`
+		Outcome  tally.Outcome `json:"outcome"`
+	}
+	tests := []struct {
+		name   string
+		hash   string
+		status int
+		want   response
+	}{
+		{"invalid hash", "receipt", http.StatusBadRequest, response{}},
+		{"unknown hash", strings.Repeat("0", 64), http.StatusNotFound, response{}},
+		{"recorded ballot", first.Hash, http.StatusOK, response{Receipt: first, Recorded: true}},
+		{"upper-case hash", strings.ToUpper(second.Hash), http.StatusOK, response{Receipt: second, Recorded: true}},
+		{"ballot only on the ledger", direct, http.StatusNotFound, response{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := do(t, s, http.MethodGet, "/receipts/"+test.hash, "", nil)
+			if test.status != http.StatusOK {
+				if w.Code != test.status {
+					t.Errorf("got status %d, want %d: %s", w.Code, test.status, w.Body.String())
+				}
+				return
+			}
+			var res response
+			decode(t, w, http.StatusOK, &res)
+			if res != test.want {
+				t.Errorf("got %+v, want %+v", res, test.want)
+			}
+		})
+	}
+
+	// Once the results are published, receipts also tell whether their ballot counted.
+	setStatus(t, s, election.DefaultID, election.Closed)
+	decode(t, do(t, s, http.MethodPost, "/admin/tally", admin, map[string]any{}), http.StatusOK, &struct{}{})
+	if w := do(t, s, http.MethodPost, "/admin/status", admin, map[string]any{"status": election.Published}); w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body.String())
+	}
+	for _, test := range []struct {
+		hash     string
+		recorded bool
+		outcome  tally.Outcome
+	}{
+		{first.Hash, true, tally.Counted},
+		{second.Hash, true, tally.Duplicate},
+		{direct, false, tally.Counted},
+	} {
+		var res response
+		decode(t, do(t, s, http.MethodGet, "/receipts/"+test.hash, "", nil), http.StatusOK, &res)
+		if res.Hash != test.hash || res.Recorded != test.recorded || !res.Tallied || res.Outcome != test.outcome || res.KeyImage == "" {
+			t.Errorf("got %+v, want recorded %v and %s", res, test.recorded, test.outcome)
+		}
+	}
+}
+
+func TestGetBulletin(t *testing.T) {
+	s := newTestServer(t, "simulation-full", 4)
+	var hashes []string
+	for i, email := range []string{"user1@sentinelvote.tech", "user2@sentinelvote.tech", "user3@sentinelvote.tech"} {
+		receipt := castBallot(t, s, email, ballot.Ballot{Election: election.DefaultID, Constituency: "BEDOK", Candidate: int64(i%2 + 1)})
+		hashes = append(hashes, receipt.Hash)
+	}
+	setStatus(t, s, election.DefaultID, election.Closed)
+
+	type page struct {
+		Entries []bulletin.Entry `json:"entries"`
+		Next    int64            `json:"next"`
+	}
+	var first, last, all page
+	decode(t, do(t, s, http.MethodGet, "/bulletin?limit=2", "", nil), http.StatusOK, &first)
+	if len(first.Entries) != 2 || first.Entries[0].Hash != hashes[0] || first.Entries[1].Hash != hashes[1] || first.Next != first.Entries[1].ID {
+		t.Fatalf("got first page %+v", first)
+	}
+	decode(t, do(t, s, http.MethodGet, fmt.Sprintf("/bulletin?limit=2&after=%d", first.Next), "", nil), http.StatusOK, &last)
+	if len(last.Entries) != 1 || last.Entries[0].Hash != hashes[2] || last.Next != 0 {
+		t.Fatalf("got last page %+v", last)
+	}
+	decode(t, do(t, s, http.MethodGet, "/bulletin", "", nil), http.StatusOK, &all)
+	if len(all.Entries) != 3 || all.Next != 0 {
+		t.Fatalf("got whole bulletin %+v", all)
+	}
+	for _, path := range []string{"/bulletin?limit=0", "/bulletin?limit=1001", "/bulletin?limit=ten", "/bulletin?after=-1"} {
+		if w := do(t, s, http.MethodGet, path, "", nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want %d", path, w.Code, http.StatusBadRequest)
+		}
+	}
+
+	// The archive has the same entries, one per line.
+	w := do(t, s, http.MethodGet, "/bulletin/archive", "", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/jsonl" {
+		t.Fatalf("got status %d and %s: %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if len(lines) != len(all.Entries) {
+		t.Fatalf("got %d lines in the archive, want %d", len(lines), len(all.Entries))
+	}
+	for i, line := range lines {
+		var entry bulletin.Entry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry != all.Entries[i] {
+			t.Errorf("got %+v, want %+v", entry, all.Entries[i])
+		}
+	}
+}
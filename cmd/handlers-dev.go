@@ -3,6 +3,7 @@ package cmd
 // Standard library on top, third-party packages below.
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,6 +16,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/goccy/go-json"
 	"github.com/sentinelvote/backend/internal/db"
+	"github.com/sentinelvote/backend/internal/foldpub"
 	"zombiezen.com/go/sqlite/sqlitex"
 )
 
@@ -128,7 +130,7 @@ func meminfoParse() (map[string]int, error) {
 	defer func(file *os.File) {
 		err := file.Close()
 		if err != nil {
-			panic(err)
+			log.Println("Error closing /proc/meminfo: " + err.Error())
 		}
 	}(file)
 
@@ -162,11 +164,11 @@ func meminfoParse() (map[string]int, error) {
 // |                                           Database                                           |
 // +----------------------------------------------------------------------------------------------+
 
+// handleDevDatabaseReset submits a job that recreates the database with the given schema and number of users.
+// Cancelling the job interrupts the schema's transaction, leaving the database as it was,
+// or, once a full simulation has been created, the publishing of its ring, see createSchema.
 func (s *Server) handleDevDatabaseReset() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		conn := s.Database.Get(r.Context())
-		defer s.Database.Put(conn)
-
 		schema := chi.URLParam(r, "schema")
 		var purpose int
 		if schema == "production" {
@@ -186,17 +188,30 @@ func (s *Server) handleDevDatabaseReset() http.HandlerFunc {
 			return
 		}
 
-		err = db.CreateSchema(conn, purpose, initialUserCount)
-		if err != nil {
-			http.Error(w, "Error creating schema", http.StatusInternalServerError)
-			return
-		}
-
-		_, err = w.Write([]byte(fmt.Sprintf("Created %s schema with %d users", schema, initialUserCount)))
-		if err != nil {
-			log.Println(err)
-			http.Error(w, "Error writing response", http.StatusInternalServerError)
-		}
+		j, err := s.submitJob(jobDatabaseReset, 0, func(ctx context.Context, progress func(jobProgress)) (any, error) {
+			// The embedded ledger refers to the old keys, so it is recreated with the database.
+			if ledger, ok := s.Ledger.(*foldpub.SQLite); ok {
+				progress(jobProgress{Phase: "resetting the ledger"})
+				if err := ledger.Reset(ctx); err != nil {
+					return nil, fmt.Errorf("error resetting the ledger: %w", err)
+				}
+			}
+
+			progress(jobProgress{Phase: "creating schema"})
+			conn := s.Database.Get(ctx)
+			if conn == nil {
+				return nil, ctx.Err()
+			}
+			defer s.Database.Put(conn)
+			if err := s.createSchema(ctx, conn, purpose, initialUserCount, progress); err != nil {
+				return nil, fmt.Errorf("error creating schema: %w", err)
+			}
+			if err := s.loadSchema(conn); err != nil {
+				return nil, fmt.Errorf("error reading schema: %w", err)
+			}
+			return fmt.Sprintf("Created %s schema with %d users", schema, initialUserCount), nil
+		})
+		respondSubmittedJob(&w, j, err)
 	}
 }
 
@@ -209,7 +224,6 @@ func (s *Server) handleDevDatabaseGetFullDatabase() http.HandlerFunc {
 					'email', email,
 					'password', password,
 					'publicKey', public_key,
-					'hasVoted', has_voted,
 					'constituency', constituency,
 					'firstName', first_name,
 					'lastName', last_name,
@@ -217,10 +231,31 @@ func (s *Server) handleDevDatabaseGetFullDatabase() http.HandlerFunc {
 					'privateKey', private_key
 				)) FROM users
 			),
-			'isEndOfElection', (
+			'elections', (
 				SELECT json_group_array(json_object(
-					'isEndOfElection', is_end_of_election
-				)) FROM is_end_of_election
+					'id', id,
+					'title', title,
+					'opensAt', opens_at,
+					'closesAt', closes_at,
+					'status', status,
+					'createdAt', created_at
+				)) FROM elections
+			),
+			'electionVoters', (
+				SELECT json_group_array(json_object(
+					'electionID', election_id,
+					'userUUID', user_uuid,
+					'hasVoted', has_voted
+				)) FROM election_voters
+			),
+			'auditLog', (
+				SELECT json_group_array(json_object(
+					'id', id,
+					'actorUUID', actor_uuid,
+					'action', action,
+					'targetUUID', target_uuid,
+					'createdAt', created_at
+				)) FROM audit_log
 			)
 		) as result;`
 
@@ -248,21 +283,40 @@ func (s *Server) handleDevDatabaseGetFullDatabase() http.HandlerFunc {
 // |                                          Blockchain                                          |
 // +----------------------------------------------------------------------------------------------+
 
-// handleDevBlockchainReset resets the blockchain by recreating it.
+// handleDevBlockchainReset submits a job that resets the blockchain by recreating it.
+// With the embedded ledger, its folded public keys and ballots are removed instead.
 func (s *Server) handleDevBlockchainReset() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if err := os.Chdir("../blockchain"); err != nil {
-			http.Error(w, "Error changing directory", http.StatusInternalServerError)
-		} else if err := exec.Command("fablo", "prune").Run(); err != nil {
-			http.Error(w, "Error destroying the blockchain", http.StatusInternalServerError)
-		} else if err := exec.Command("fablo", "generate").Run(); err != nil {
-			http.Error(w, "Error generating the blockchain configuration", http.StatusInternalServerError)
-		} else if err := exec.Command("fablo", "up").Run(); err != nil {
-			http.Error(w, "Error recreating the blockchain", http.StatusInternalServerError)
-		} else if err := os.Chdir("../backend"); err != nil {
-			http.Error(w, "Error changing directory", http.StatusInternalServerError)
-		} else {
-			respondPlainText(&w, "Successfully recreated the blockchain.")
-		}
+	// The fablo commands are run from the blockchain repository, next to this one.
+	// The working directory of the server is left alone, since other requests are served meanwhile.
+	steps := []struct {
+		command string
+		failure string
+	}{
+		{"prune", "error destroying the blockchain"},
+		{"generate", "error generating the blockchain configuration"},
+		{"up", "error recreating the blockchain"},
+	}
+
+	return func(w http.ResponseWriter, _ *http.Request) {
+		j, err := s.submitJob(jobBlockchainReset, 0, func(ctx context.Context, progress func(jobProgress)) (any, error) {
+			if ledger, ok := s.Ledger.(*foldpub.SQLite); ok {
+				progress(jobProgress{Phase: "resetting the embedded ledger"})
+				if err := ledger.Reset(ctx); err != nil {
+					return nil, fmt.Errorf("error resetting the ledger: %w", err)
+				}
+				return "Successfully reset the embedded ledger.", nil
+			}
+
+			for i, step := range steps {
+				progress(jobProgress{Phase: "fablo " + step.command, Done: i, Total: len(steps)})
+				command := exec.CommandContext(ctx, "fablo", step.command)
+				command.Dir = "../blockchain"
+				if err := command.Run(); err != nil {
+					return nil, fmt.Errorf("%s: %w", step.failure, err)
+				}
+			}
+			return "Successfully recreated the blockchain.", nil
+		})
+		respondSubmittedJob(&w, j, err)
 	}
 }
@@ -0,0 +1,461 @@
+package cmd
+
+// Standard library on top, application and third-party packages below.
+import (
+	"errors"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/sentinelvote/backend/internal/bulletin"
+	"github.com/sentinelvote/backend/internal/election"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// This file contains the handlers managing elections and their voter rolls.
+// Handlers acting within a single election are in handlers.go, and read the
+// election from the request context (see withElection).
+
+// handleGetElections lists every election.
+func (s *Server) handleGetElections() http.HandlerFunc {
+	const query = `
+		SELECT json_group_array(json_object(
+			'id', e.id,
+			'title', e.title,
+			'opensAt', e.opens_at,
+			'closesAt', e.closes_at,
+			'status', e.status,
+			'ringMode', e.ring_mode,
+			'ringVersion', e.ring_version,
+			'voters', (SELECT COUNT(*) FROM election_voters v WHERE v.election_id = e.id)
+		)) as result
+		FROM elections e
+		ORDER BY e.id;`
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn := s.Database.Get(r.Context())
+		defer s.Database.Put(conn)
+
+		jsonResponse, err := sqlitex.ResultText(conn.Prep(query))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		respondJSON(&w, jsonResponse)
+	}
+}
+
+// handleAdminCreateElection creates an election.
+// If constituency is set, voters of that constituency are enrolled onto its voter roll,
+// and if enrollAll is set, every voter is enrolled.
+// ringMode is 'election' (the default) for one ring of every voter, or 'constituency' for one ring per constituency.
+func (s *Server) handleAdminCreateElection() http.HandlerFunc {
+	type request struct {
+		Title        string            `json:"title"`
+		OpensAt      *time.Time        `json:"opensAt"`
+		ClosesAt     *time.Time        `json:"closesAt"`
+		Constituency string            `json:"constituency"`
+		EnrollAll    bool              `json:"enrollAll"`
+		RingMode     election.RingMode `json:"ringMode"`
+	}
+	type response struct {
+		ID       int64 `json:"id"`
+		Enrolled int   `json:"enrolled"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isHeaderJSON(w, r) {
+			return
+		}
+		defer bodyClose(r.Body)
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Title == "" {
+			http.Error(w, "Missing title parameter", http.StatusBadRequest)
+			return
+		}
+		if req.OpensAt != nil && req.ClosesAt != nil && !req.ClosesAt.After(*req.OpensAt) {
+			http.Error(w, "closesAt must be after opensAt", http.StatusBadRequest)
+			return
+		}
+		req.Constituency = strings.ToUpper(strings.TrimSpace(req.Constituency))
+		if req.RingMode == "" {
+			req.RingMode = election.RingPerElection
+		} else if !req.RingMode.Valid() {
+			http.Error(w, "Unknown ringMode, use 'election' or 'constituency'", http.StatusBadRequest)
+			return
+		}
+
+		conn := s.Database.Get(r.Context())
+		defer s.Database.Put(conn)
+
+		if req.Constituency != "" && !requireConstituency(w, conn, req.Constituency) {
+			return
+		}
+
+		var err error
+		defer sqlitex.Save(conn)(&err)
+
+		err = sqlitex.Execute(conn, `INSERT INTO elections (title, opens_at, closes_at, ring_mode) VALUES (?, ?, ?, ?);`, &sqlitex.ExecOptions{
+			Args: []any{req.Title, unixOrNil(req.OpensAt), unixOrNil(req.ClosesAt), req.RingMode},
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		id := conn.LastInsertRowID()
+
+		var enrolled int
+		if req.EnrollAll || req.Constituency != "" {
+			err = sqlitex.Execute(conn, `
+				INSERT INTO election_voters (election_id, user_uuid)
+				SELECT ?, uuid FROM users
+				WHERE is_central_authority = FALSE AND (? OR constituency = ?);`,
+				&sqlitex.ExecOptions{
+					Args: []any{id, req.EnrollAll, req.Constituency},
+				})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			enrolled = conn.Changes()
+		}
+
+		jsonResponse, err := json.Marshal(response{ID: id, Enrolled: enrolled})
+		if err != nil {
+			http.Error(w, "Error converting response to JSON", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(&w, jsonResponse)
+	}
+}
+
+// handleAdminEnrollVoters adds voters onto the voter roll of the election in the request context,
+// either by email or by constituency. Voters who are already enrolled are skipped.
+func (s *Server) handleAdminEnrollVoters() http.HandlerFunc {
+	type request struct {
+		Emails       []string `json:"emails"`
+		Constituency string   `json:"constituency"`
+	}
+	type response struct {
+		Enrolled int `json:"enrolled"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isHeaderJSON(w, r) {
+			return
+		}
+		defer bodyClose(r.Body)
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		req.Constituency = strings.ToUpper(strings.TrimSpace(req.Constituency))
+		if len(req.Emails) == 0 && req.Constituency == "" {
+			http.Error(w, "Missing emails or constituency parameter", http.StatusBadRequest)
+			return
+		}
+		for _, email := range req.Emails {
+			if _, err := mail.ParseAddress(email); err != nil {
+				http.Error(w, "Invalid email: "+email, http.StatusBadRequest)
+				return
+			}
+		}
+
+		conn := s.Database.Get(r.Context())
+		defer s.Database.Put(conn)
+		e, _ := election.FromContext(r.Context())
+		if req.Constituency != "" && !requireConstituency(w, conn, req.Constituency) {
+			return
+		}
+
+		var err error
+		defer sqlitex.Save(conn)(&err)
+
+		var enrolled int
+		enroll := func(filter string, arg any) error {
+			err := sqlitex.Execute(conn, `
+				INSERT OR IGNORE INTO election_voters (election_id, user_uuid)
+				SELECT ?, uuid FROM users
+				WHERE is_central_authority = FALSE AND `+filter+` = ?;`,
+				&sqlitex.ExecOptions{
+					Args: []any{e.ID, arg},
+				})
+			enrolled += conn.Changes()
+			return err
+		}
+		for _, email := range req.Emails {
+			if err = enroll("email", email); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if req.Constituency != "" {
+			if err = enroll("constituency", req.Constituency); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		jsonResponse, err := json.Marshal(response{Enrolled: enrolled})
+		if err != nil {
+			http.Error(w, "Error converting response to JSON", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(&w, jsonResponse)
+	}
+}
+
+// requireConstituency reports whether any voter lives in a constituency, upper-cased as voters' are,
+// so that a misspelt constituency is not taken for an empty one. It writes the error to the response if none does.
+func requireConstituency(w http.ResponseWriter, conn *sqlite.Conn, constituency string) bool {
+	var known bool
+	err := sqlitex.Execute(conn, `SELECT EXISTS (SELECT 1 FROM users WHERE is_central_authority = FALSE AND constituency = ?);`, &sqlitex.ExecOptions{
+		Args: []any{constituency},
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			known = stmt.ColumnBool(0)
+			return nil
+		},
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if !known {
+		http.Error(w, "No voter lives in constituency "+constituency, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// unixOrNil converts an optional timestamp to unix seconds, or nil for SQL NULL.
+func unixOrNil(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.Unix()
+}
+
+// handleGetElectionStatus returns the election in the request context,
+// with its lifecycle status and the statuses it may move to next.
+func (s *Server) handleGetElectionStatus() http.HandlerFunc {
+	type response struct {
+		ID          int64             `json:"id"`
+		Title       string            `json:"title"`
+		OpensAt     int64             `json:"opensAt,omitempty"`
+		ClosesAt    int64             `json:"closesAt,omitempty"`
+		Status      election.Status   `json:"status"`
+		Next        []election.Status `json:"next"`
+		Ended       bool              `json:"ended"`
+		RingMode    election.RingMode `json:"ringMode"`
+		RingVersion int               `json:"ringVersion"`
+		Turnout     int               `json:"turnout"` // Voters who have cast a ballot, see bulletin.Turnout
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		e, _ := election.FromContext(r.Context())
+		conn := s.Database.Get(r.Context())
+		turnout, err := bulletin.Turnout(conn, e.ID)
+		s.Database.Put(conn)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		jsonResponse, err := json.Marshal(response{
+			ID:          e.ID,
+			Title:       e.Title,
+			OpensAt:     e.OpensAt,
+			ClosesAt:    e.ClosesAt,
+			Status:      e.Status,
+			Next:        e.Status.Next(),
+			Ended:       e.Status.Ended(),
+			RingMode:    e.RingMode,
+			RingVersion: e.RingVersion,
+			Turnout:     turnout,
+		})
+		if err != nil {
+			http.Error(w, "Error converting response to JSON", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(&w, jsonResponse)
+	}
+}
+
+// handleAdminTransitionElection moves the election in the request context to the next status of its lifecycle.
+func (s *Server) handleAdminTransitionElection() http.HandlerFunc {
+	type request struct {
+		Status election.Status `json:"status"`
+	}
+	type response struct {
+		From election.Status `json:"from"`
+		To   election.Status `json:"to"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isHeaderJSON(w, r) {
+			return
+		}
+		defer bodyClose(r.Body)
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		conn := s.Database.Get(r.Context())
+		defer s.Database.Put(conn)
+
+		e, _ := election.FromContext(r.Context())
+		if req.Status == election.Voting {
+			if published, err := ringsPublished(conn, e); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			} else if !published {
+				http.Error(w, "The ring must be published before voting opens", http.StatusConflict)
+				return
+			}
+		}
+		err := election.Transition(conn, e.ID, e.Status, req.Status)
+		if errors.Is(err, election.ErrUnknownStatus) {
+			http.Error(w, "Unknown status", http.StatusBadRequest)
+			return
+		} else if errors.Is(err, election.ErrInvalidTransition) || errors.Is(err, election.ErrStatusChanged) {
+			http.Error(w, "Unable to move the election from '"+string(e.Status)+"' to '"+string(req.Status)+"'", http.StatusConflict)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.wakeScheduler()
+
+		jsonResponse, err := json.Marshal(response{From: e.Status, To: req.Status})
+		if err != nil {
+			http.Error(w, "Error converting response to JSON", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(&w, jsonResponse)
+	}
+}
+
+// handleAdminReopenRegistration moves the election in the request context from keys-frozen back to registration,
+// so that voters may change their public keys, and bumps its ring version (see election.ReopenRegistration).
+// Once keys are frozen again, the rings must be published again before voting opens.
+func (s *Server) handleAdminReopenRegistration() http.HandlerFunc {
+	type response struct {
+		From        election.Status `json:"from"`
+		To          election.Status `json:"to"`
+		RingVersion int             `json:"ringVersion"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn := s.Database.Get(r.Context())
+		defer s.Database.Put(conn)
+
+		e, _ := election.FromContext(r.Context())
+		err := election.ReopenRegistration(conn, e.ID)
+		if errors.Is(err, election.ErrStatusChanged) {
+			http.Error(w, "Registration can only be reopened while keys are frozen", http.StatusConflict)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		jsonResponse, err := json.Marshal(response{From: e.Status, To: election.Registration, RingVersion: e.RingVersion + 1})
+		if err != nil {
+			http.Error(w, "Error converting response to JSON", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(&w, jsonResponse)
+	}
+}
+
+// handleAdminGetSchedule returns the voting window of the election in the request context.
+func (s *Server) handleAdminGetSchedule() http.HandlerFunc {
+	type response struct {
+		OpensAt  *time.Time      `json:"opensAt"`
+		ClosesAt *time.Time      `json:"closesAt"`
+		Status   election.Status `json:"status"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		e, _ := election.FromContext(r.Context())
+		jsonResponse, err := json.Marshal(response{
+			OpensAt:  timeOrNil(e.OpensAt),
+			ClosesAt: timeOrNil(e.ClosesAt),
+			Status:   e.Status,
+		})
+		if err != nil {
+			http.Error(w, "Error converting response to JSON", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(&w, jsonResponse)
+	}
+}
+
+// handleAdminUpdateSchedule replaces the voting window of the election in the request context.
+// A null opensAt or closesAt leaves that transition to a central authority.
+func (s *Server) handleAdminUpdateSchedule() http.HandlerFunc {
+	type request struct {
+		OpensAt  *time.Time `json:"opensAt"`
+		ClosesAt *time.Time `json:"closesAt"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isHeaderJSON(w, r) {
+			return
+		}
+		defer bodyClose(r.Body)
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.OpensAt != nil && req.ClosesAt != nil && !req.ClosesAt.After(*req.OpensAt) {
+			http.Error(w, "closesAt must be after opensAt", http.StatusBadRequest)
+			return
+		}
+
+		conn := s.Database.Get(r.Context())
+		defer s.Database.Put(conn)
+
+		e, _ := election.FromContext(r.Context())
+		if e.Status.Ended() {
+			http.Error(w, "The election has already been closed", http.StatusConflict)
+			return
+		}
+		if e.Status == election.Voting && req.OpensAt != nil && req.OpensAt.Unix() != e.OpensAt {
+			http.Error(w, "The election is already open, only closesAt may change", http.StatusConflict)
+			return
+		}
+
+		err := sqlitex.Execute(conn, `UPDATE elections SET opens_at = ?, closes_at = ? WHERE id = ?;`, &sqlitex.ExecOptions{
+			Args: []any{unixOrNil(req.OpensAt), unixOrNil(req.ClosesAt), e.ID},
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.wakeScheduler()
+
+		respondPlainText(&w, "Successfully updated the schedule")
+	}
+}
+
+// timeOrNil converts unix seconds to a timestamp, or nil when unscheduled.
+func timeOrNil(unix int64) *time.Time {
+	if unix == 0 {
+		return nil
+	}
+	t := time.Unix(unix, 0).UTC()
+	return &t
+}
@@ -0,0 +1,73 @@
+package cmd
+
+// Standard library on top, application and third-party packages below.
+import (
+	"net/http"
+	"testing"
+
+	"github.com/sentinelvote/backend/internal/election"
+)
+
+func TestAdminTransitionElection(t *testing.T) {
+	s := newTestServer(t, "simulation", 4)
+	admin := login(t, s, "admin@sentinelvote.tech")
+
+	tests := []struct {
+		name string
+		from election.Status
+		to   election.Status
+		want int
+	}{
+		{"next status", election.Registration, election.KeysFrozen, http.StatusOK},
+		{"skipping a status", election.Registration, election.Voting, http.StatusConflict},
+		{"going back", election.Voting, election.KeysFrozen, http.StatusConflict},
+		{"voting without a ring", election.KeysFrozen, election.Voting, http.StatusConflict},
+		{"unknown status", election.Voting, "paused", http.StatusBadRequest},
+		{"closing", election.Voting, election.Closed, http.StatusOK},
+		{"after publishing", election.Published, election.Draft, http.StatusConflict},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setStatus(t, s, election.DefaultID, test.from)
+			w := do(t, s, http.MethodPost, "/admin/status", admin, map[string]any{"status": test.to})
+			if w.Code != test.want {
+				t.Fatalf("got status %d, want %d: %s", w.Code, test.want, w.Body.String())
+			}
+			want := test.from
+			if w.Code == http.StatusOK {
+				want = test.to
+			}
+			var res struct {
+				Status election.Status `json:"status"`
+			}
+			decode(t, do(t, s, http.MethodGet, "/status", "", nil), http.StatusOK, &res)
+			if res.Status != want {
+				t.Errorf("got election in %s, want %s", res.Status, want)
+			}
+		})
+	}
+}
+
+func TestAdminAnnounceResult(t *testing.T) {
+	s := newTestServer(t, "simulation", 4)
+	admin := login(t, s, "admin@sentinelvote.tech")
+
+	tests := []struct {
+		from   election.Status
+		method string
+		want   int
+	}{
+		{election.Registration, http.MethodPost, http.StatusConflict},
+		{election.Voting, http.MethodPost, http.StatusOK},
+		{election.Voting, http.MethodGet, http.StatusOK},
+		{election.Closed, http.MethodPost, http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(string(test.from)+" "+test.method, func(t *testing.T) {
+			setStatus(t, s, election.DefaultID, test.from)
+			if w := do(t, s, test.method, "/admin/announce", admin, nil); w.Code != test.want {
+				t.Fatalf("got status %d, want %d: %s", w.Code, test.want, w.Body.String())
+			}
+		})
+	}
+}
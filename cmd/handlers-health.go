@@ -0,0 +1,50 @@
+package cmd
+
+// Standard library on top, application and third-party packages below.
+import (
+	"log"
+	"net/http"
+
+	"github.com/goccy/go-json"
+	"github.com/sentinelvote/backend/internal/foldpub"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// handleHealth reports whether the database and the ledger are reachable.
+// An open circuit to the ledger only degrades the service, since voters can still sign ballots,
+// so the response is 503 Service Unavailable only if the database is down.
+func (s *Server) handleHealth() http.HandlerFunc {
+	type response struct {
+		Status   string         `json:"status"` // "ok", "degraded" or "down"
+		Database string         `json:"database"`
+		Ledger   foldpub.Health `json:"ledger"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		res := response{Status: "ok", Database: "ok", Ledger: s.Ledger.Health()}
+		if res.Ledger.Circuit != "" && res.Ledger.Circuit != foldpub.CircuitClosed {
+			res.Status = "degraded"
+		}
+
+		status := http.StatusOK
+		if conn := s.Database.Get(r.Context()); conn == nil {
+			res.Status, res.Database, status = "down", "unavailable", http.StatusServiceUnavailable
+		} else {
+			if _, err := sqlitex.ResultInt(conn.Prep("SELECT 1;")); err != nil {
+				res.Status, res.Database, status = "down", err.Error(), http.StatusServiceUnavailable
+			}
+			s.Database.Put(conn)
+		}
+
+		jsonResponse, err := json.Marshal(res)
+		if err != nil {
+			http.Error(w, "Error converting response to JSON", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if _, err := w.Write(jsonResponse); err != nil {
+			log.Println("Error writing response: " + err.Error())
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package cmd
+
+// Standard library on top, application and third-party packages below.
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/goccy/go-json"
+	"github.com/sentinelvote/backend/internal/election"
+)
+
+// handleAdminGetJobs returns the latest jobs in JSON, newest first.
+// Add ?kind= to only return jobs of one kind, e.g. fold-rings.
+func (s *Server) handleAdminGetJobs() http.HandlerFunc {
+	const limit = 100
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn := s.Database.Get(r.Context())
+		defer s.Database.Put(conn)
+
+		jobs, err := listJobs(conn, r.URL.Query().Get("kind"), limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		jsonResponse, err := json.Marshal(jobs)
+		if err != nil {
+			http.Error(w, "Error converting response to JSON", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(&w, jsonResponse)
+	}
+}
+
+// handleGetJob returns a job in JSON, with its progress, and its result or error once it has finished.
+func (s *Server) handleGetJob() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn := s.Database.Get(r.Context())
+		defer s.Database.Put(conn)
+
+		j, found, err := getJob(conn, chi.URLParam(r, "jobID"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		respondJob(&w, http.StatusOK, j)
+	}
+}
+
+// handleAdminCancelJob cancels a running job. It returns 202 Accepted with the job,
+// whose status becomes cancelled once it has stopped.
+func (s *Server) handleAdminCancelJob() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "jobID")
+		if !s.cancelJob(id) {
+			http.Error(w, "No such job is running", http.StatusNotFound)
+			return
+		}
+
+		conn := s.Database.Get(r.Context())
+		defer s.Database.Put(conn)
+		j, _, err := getJob(conn, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJob(&w, http.StatusAccepted, j)
+	}
+}
+
+// handleAdminGetFoldJob returns the latest job that folded the rings of the election in the request context,
+// see handleAdminPutFoldedPublicKeys. It is a shorthand for handleGetJob.
+func (s *Server) handleAdminGetFoldJob() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn := s.Database.Get(r.Context())
+		defer s.Database.Put(conn)
+
+		e, _ := election.FromContext(r.Context())
+		j, found, err := latestJob(conn, jobFoldRings, e.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "The rings of this election have never been folded", http.StatusNotFound)
+			return
+		}
+		respondJob(&w, http.StatusOK, j)
+	}
+}
+
+// handleAdminCancelFoldJob cancels the running job that folds the rings of the election in the request context.
+// It is a shorthand for handleAdminCancelJob.
+func (s *Server) handleAdminCancelFoldJob() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn := s.Database.Get(r.Context())
+		defer s.Database.Put(conn)
+
+		e, _ := election.FromContext(r.Context())
+		j, found, err := latestJob(conn, jobFoldRings, e.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !found || !s.cancelJob(j.ID) {
+			http.Error(w, "No such job is running", http.StatusNotFound)
+			return
+		}
+		if j, _, err = getJob(conn, j.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJob(&w, http.StatusAccepted, j)
+	}
+}
+
+// respondSubmittedJob writes the outcome of submitJob: the job with 202 Accepted,
+// or the job already running with 409 Conflict.
+func respondSubmittedJob(w *http.ResponseWriter, j job, err error) {
+	if errors.Is(err, errJobRunning) {
+		respondJob(w, http.StatusConflict, j)
+	} else if err != nil {
+		http.Error(*w, err.Error(), http.StatusInternalServerError)
+	} else {
+		respondJob(w, http.StatusAccepted, j)
+	}
+}
+
+// respondJob writes a job in JSON with the given status code.
+func respondJob(w *http.ResponseWriter, statusCode int, j job) {
+	jsonResponse, err := json.Marshal(j)
+	if err != nil {
+		http.Error(*w, "Error converting response to JSON", http.StatusInternalServerError)
+		return
+	}
+	(*w).Header().Set("Content-Type", "application/json")
+	(*w).WriteHeader(statusCode)
+	if _, err := (*w).Write(jsonResponse); err != nil {
+		log.Println("Error writing response: " + err.Error())
+	}
+}
@@ -0,0 +1,83 @@
+package cmd
+
+// Standard library on top, application and third-party packages below.
+import (
+	"net/http"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/sentinelvote/backend/internal/election"
+	"github.com/sentinelvote/backend/internal/foldpub"
+	"zombiezen.com/go/sqlite"
+)
+
+// This file contains the handlers exposing the rings (folded public keys) published to the ledger.
+// See internal/foldpub for how rings are recorded.
+
+// handleGetCurrentRing returns the latest ring published for the election in the request context.
+// Elections with one ring per constituency require the constituency query parameter.
+// The ETag is the hash of the ring, so a client can detect a changed ring with If-None-Match.
+func (s *Server) handleGetCurrentRing() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		e, _ := election.FromContext(r.Context())
+		constituency := strings.ToUpper(r.URL.Query().Get("constituency"))
+		if e.RingMode == election.RingPerConstituency && constituency == "" {
+			http.Error(w, "Missing constituency parameter, this election has one ring per constituency", http.StatusBadRequest)
+			return
+		}
+
+		conn := s.Database.Get(r.Context())
+		defer s.Database.Put(conn)
+
+		snapshot, found, err := currentRing(conn, e, constituency)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "No ring has been published for this election", http.StatusNotFound)
+			return
+		}
+
+		// Let clients keep the ring, as long as they revalidate it, instead of the no-store set by noCache.
+		etag := `"` + snapshot.Hash + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Del("Expires")
+		w.Header().Del("Pragma")
+		w.Header().Del("X-Accel-Expires")
+		if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		jsonResponse, err := json.Marshal(snapshot)
+		if err != nil {
+			http.Error(w, "Error converting response to JSON", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(&w, jsonResponse)
+	}
+}
+
+// currentRing returns the ring a ballot for constituency is signed with: the ring of the election,
+// or the ring of the constituency for elections with one ring per constituency.
+func currentRing(conn *sqlite.Conn, e election.Election, constituency string) (foldpub.Snapshot, bool, error) {
+	if e.RingMode != election.RingPerConstituency {
+		constituency = ""
+	} else if constituency == "" {
+		return foldpub.Snapshot{}, false, nil
+	}
+	return foldpub.CurrentSnapshot(conn, e.ID, constituency)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, or is a wildcard.
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
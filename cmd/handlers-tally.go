@@ -0,0 +1,279 @@
+package cmd
+
+// Standard library on top, application and third-party packages below.
+import (
+	"net/http"
+
+	"github.com/goccy/go-json"
+	"github.com/sentinelvote/backend/internal/ballot"
+	"github.com/sentinelvote/backend/internal/bulletin"
+	"github.com/sentinelvote/backend/internal/election"
+	"github.com/sentinelvote/backend/internal/tally"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// handleAdminTally counts the ballots of the election in the request context,
+// stores the totals, and moves the election from closed to tallied.
+func (s *Server) handleAdminTally() http.HandlerFunc {
+	type request struct {
+		Policy tally.Policy `json:"policy"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isHeaderJSON(w, r) {
+			return
+		}
+		defer bodyClose(r.Body)
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Policy == "" {
+			req.Policy = tally.FirstWins
+		} else if !req.Policy.Valid() {
+			http.Error(w, "Unknown policy, use 'first-wins' or 'last-wins'", http.StatusBadRequest)
+			return
+		}
+
+		conn := s.Database.Get(r.Context())
+		defer s.Database.Put(conn)
+		e, _ := election.FromContext(r.Context())
+
+		// Verify against the rings that were published, and that POST /ballots verified ballots against.
+		opts := tally.Options{
+			CaseIdentifier: election.CaseIdentifier(e.ID),
+			Policy:         req.Policy,
+		}
+		rings, published, err := currentRings(conn, e)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !published {
+			http.Error(w, "The rings of this election have not been published", http.StatusConflict)
+			return
+		}
+		if e.RingMode == election.RingPerConstituency {
+			opts.Rings = make(map[string][]byte, len(rings))
+			for constituency, snapshot := range rings {
+				opts.Rings[constituency] = []byte(snapshot.FoldedPublicKeys)
+			}
+		} else {
+			opts.FoldedPublicKeys = []byte(rings[""].FoldedPublicKeys)
+		}
+		candidates, err := ballot.ListCandidates(conn, e.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ballots, err := s.Ledger.ListBallots(r.Context(), e.ID)
+		if err != nil {
+			respondLedgerError(&w, err)
+			return
+		}
+		opts.Eligible = ballot.Eligible(e.ID, candidates)
+		result, err := tally.Count(ballots, opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := saveTally(conn, e.ID, ballots, result); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		jsonResponse, err := json.Marshal(newResults(result, candidates))
+		if err != nil {
+			http.Error(w, "Error converting response to JSON", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(&w, jsonResponse)
+	}
+}
+
+// handleGetResults returns the totals of the election in the request context.
+// Routes decide whether the results may be seen yet, see requireStatus.
+func (s *Server) handleGetResults() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn := s.Database.Get(r.Context())
+		defer s.Database.Put(conn)
+		e, _ := election.FromContext(r.Context())
+
+		result, found, err := loadTally(conn, e.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "The election has not been tallied", http.StatusNotFound)
+			return
+		}
+		candidates, err := ballot.ListCandidates(conn, e.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		jsonResponse, err := json.Marshal(newResults(result, candidates))
+		if err != nil {
+			http.Error(w, "Error converting response to JSON", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(&w, jsonResponse)
+	}
+}
+
+// saveTally stores the totals of a count and the outcome of each of its ballots,
+// and moves the election from closed to tallied.
+func saveTally(conn *sqlite.Conn, electionID int64, ballots []tally.Ballot, result tally.Result) (err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	err = sqlitex.Execute(conn, `
+		INSERT INTO tallies (election_id, policy, total, counted, invalid, duplicates, constituency_rings)
+		VALUES (?, ?, ?, ?, ?, ?, ?);`,
+		&sqlitex.ExecOptions{
+			Args: []any{electionID, string(result.Policy), result.Total, result.Counted, result.Invalid, result.Duplicates,
+				result.ConstituenciesVerified},
+		})
+	if err != nil {
+		return err
+	}
+	for constituency, candidates := range result.Constituencies {
+		for candidate, votes := range candidates {
+			err = sqlitex.Execute(conn, `
+				INSERT INTO tally_results (election_id, constituency, candidate_id, votes) VALUES (?, ?, ?, ?);`,
+				&sqlitex.ExecOptions{
+					Args: []any{electionID, constituency, candidate, votes},
+				})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for i, b := range result.Ballots {
+		err = sqlitex.Execute(conn, `
+			INSERT INTO tally_ballots (election_id, hash, reference, key_image, outcome) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (election_id, hash) DO UPDATE SET reference = excluded.reference, outcome = excluded.outcome
+			WHERE excluded.outcome = ?;`,
+			&sqlitex.ExecOptions{
+				Args: []any{electionID, bulletin.Hash(ballots[i].Message, ballots[i].Signature), b.Reference, b.KeyImage, string(b.Outcome), string(tally.Counted)},
+			})
+		if err != nil {
+			return err
+		}
+	}
+	return election.Transition(conn, electionID, election.Closed, election.Tallied)
+}
+
+// tallyOutcome returns the outcome of a ballot in the tally of an election, by its hash,
+// and false if the tally has no such ballot.
+func tallyOutcome(conn *sqlite.Conn, electionID int64, hash string) (tally.BallotResult, bool, error) {
+	var b tally.BallotResult
+	var found bool
+	err := sqlitex.Execute(conn, `SELECT reference, key_image, outcome FROM tally_ballots WHERE election_id = ? AND hash = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{electionID, hash},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				b = tally.BallotResult{
+					Reference: stmt.ColumnText(0),
+					KeyImage:  stmt.ColumnText(1),
+					Outcome:   tally.Outcome(stmt.ColumnText(2)),
+				}
+				found = true
+				return nil
+			},
+		})
+	return b, found, err
+}
+
+// loadTally returns the stored totals of an election, and false if it has not been tallied.
+func loadTally(conn *sqlite.Conn, electionID int64) (tally.Result, bool, error) {
+	result := tally.Result{
+		Candidates:     map[int64]int{},
+		Constituencies: map[string]map[int64]int{},
+	}
+	var found bool
+	err := sqlitex.Execute(conn, `
+		SELECT policy, total, counted, invalid, duplicates, constituency_rings FROM tallies WHERE election_id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{electionID},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				result.Policy = tally.Policy(stmt.ColumnText(0))
+				result.Total = stmt.ColumnInt(1)
+				result.Counted = stmt.ColumnInt(2)
+				result.Invalid = stmt.ColumnInt(3)
+				result.Duplicates = stmt.ColumnInt(4)
+				result.ConstituenciesVerified = stmt.ColumnBool(5)
+				found = true
+				return nil
+			},
+		})
+	if err != nil || !found {
+		return result, found, err
+	}
+
+	err = sqlitex.Execute(conn, `SELECT constituency, candidate_id, votes FROM tally_results WHERE election_id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{electionID},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				constituency, candidate, votes := stmt.ColumnText(0), stmt.ColumnInt64(1), stmt.ColumnInt(2)
+				result.Candidates[candidate] += votes
+				if result.Constituencies[constituency] == nil {
+					result.Constituencies[constituency] = map[int64]int{}
+				}
+				result.Constituencies[constituency][candidate] = votes
+				return nil
+			},
+		})
+	return result, true, err
+}
+
+// candidateVotes is a candidate with their number of votes.
+type candidateVotes struct {
+	ballot.Candidate
+	Votes int `json:"votes"`
+}
+
+// results is the JSON representation of a tally.Result, with candidates in ballot order.
+type results struct {
+	Policy         tally.Policy                `json:"policy"`
+	Total          int                         `json:"total"`
+	Counted        int                         `json:"counted"`
+	Invalid        int                         `json:"invalid"`
+	Duplicates     int                         `json:"duplicates"`
+	Candidates     []candidateVotes            `json:"candidates"`
+	Constituencies map[string][]candidateVotes `json:"constituencies"`
+
+	// ConstituenciesVerified is false when the votes per constituency rest on what each ballot claims,
+	// see tally.Result.
+	ConstituenciesVerified bool `json:"constituenciesVerified"`
+}
+
+// newResults attaches candidate details to the totals of a count.
+func newResults(result tally.Result, candidates []ballot.Candidate) results {
+	res := results{
+		Policy:         result.Policy,
+		Total:          result.Total,
+		Counted:        result.Counted,
+		Invalid:        result.Invalid,
+		Duplicates:     result.Duplicates,
+		Candidates:     make([]candidateVotes, 0, len(candidates)),
+		Constituencies: map[string][]candidateVotes{},
+
+		ConstituenciesVerified: result.ConstituenciesVerified,
+	}
+	for _, c := range candidates {
+		res.Candidates = append(res.Candidates, candidateVotes{Candidate: c, Votes: result.Candidates[c.ID]})
+	}
+	for constituency, votes := range result.Constituencies {
+		for _, c := range candidates {
+			if c.Constituency == "" || c.Constituency == constituency {
+				res.Constituencies[constituency] = append(res.Constituencies[constituency], candidateVotes{Candidate: c, Votes: votes[c.ID]})
+			}
+		}
+	}
+	return res
+}
@@ -2,14 +2,22 @@ package cmd
 
 // Standard library on top, application and third-party packages below.
 import (
+	"context"
+	"errors"
 	"io"
 	"log"
 	"net/http"
 	"net/mail"
+	"os"
+	"strings"
 
 	"github.com/alexedwards/argon2id"
 	"github.com/goccy/go-json"
+	"github.com/sentinelvote/backend/internal/ballot"
+	"github.com/sentinelvote/backend/internal/election"
 	"github.com/sentinelvote/backend/internal/foldpub"
+	"github.com/sentinelvote/backend/internal/session"
+	"github.com/sentinelvote/backend/internal/voterkey"
 	"github.com/zbohm/lirisi/client"
 	"github.com/zbohm/lirisi/ring"
 	"zombiezen.com/go/sqlite"
@@ -62,6 +70,116 @@ func respondPlainText[T string | []byte](w *http.ResponseWriter, response T) {
 	}
 }
 
+// isSimulation reports whether the database was created with a simulation schema, where voters' private keys
+// may be generated, stored and returned by the server. In production, they never leave the voter's device.
+// It follows the database rather than -schema, which a resumed or reset database may not match, see loadSchema.
+func (s *Server) isSimulation() bool {
+	return s.simulation.Load()
+}
+
+// respondLedgerError writes an error from a ledger call. A transaction rejected by the ledger
+// is written as a 502 Bad Gateway with the ledger's result, so that the caller can see why.
+func respondLedgerError(w *http.ResponseWriter, err error) {
+	var chaincodeErr *foldpub.ChaincodeError
+	if errors.Is(err, foldpub.ErrCircuitOpen) {
+		http.Error(*w, err.Error(), http.StatusServiceUnavailable)
+		return
+	} else if errors.Is(err, context.DeadlineExceeded) || os.IsTimeout(err) {
+		http.Error(*w, "The ledger did not respond in time: "+err.Error(), http.StatusGatewayTimeout)
+		return
+	} else if !errors.As(err, &chaincodeErr) {
+		http.Error(*w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	jsonResponse, marshalErr := json.Marshal(struct {
+		Error  string         `json:"error"`
+		Result foldpub.Result `json:"result"`
+	}{err.Error(), chaincodeErr.Result})
+	if marshalErr != nil {
+		http.Error(*w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	(*w).Header().Set("Content-Type", "application/json")
+	(*w).WriteHeader(http.StatusBadGateway)
+	if _, err := (*w).Write(jsonResponse); err != nil {
+		log.Println("Error writing response: " + err.Error())
+	}
+}
+
+// actingVoter returns the uuid of the voter that a /voter request acts on.
+// Voters may only act on their own account, so the email is only honoured for central
+// authority overrides, which must be performed through audited.
+// It writes the error to the response if the request is not allowed.
+func actingVoter(w http.ResponseWriter, r *http.Request, conn *sqlite.Conn, email string) (string, bool) {
+	claims, ok := session.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "Missing session token", http.StatusUnauthorized)
+		return "", false
+	}
+
+	if !claims.IsCentralAuthority {
+		if email != "" && !strings.EqualFold(email, claims.Email) {
+			http.Error(w, "Voters may only act on their own account", http.StatusForbidden)
+			return "", false
+		}
+		return claims.UUID, true
+	}
+
+	// Validate email.
+	if _, err := mail.ParseAddress(email); err != nil || email == "" {
+		http.Error(w, "Invalid email", http.StatusBadRequest)
+		return "", false
+	}
+
+	var uuid string
+	err := sqlitex.Execute(conn, `SELECT uuid FROM users WHERE email = ? AND is_central_authority = FALSE;`, &sqlitex.ExecOptions{
+		Args: []any{email},
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			uuid = stmt.ColumnText(0)
+			return nil
+		},
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return "", false
+	}
+	if uuid == "" {
+		http.Error(w, "Voter not found", http.StatusNotFound)
+		return "", false
+	}
+	return uuid, true
+}
+
+// audited performs an action on the voter returned by actingVoter. If a central authority performs it,
+// it is recorded in the audit_log table, in the same savepoint: an action that fails is not recorded,
+// and an action that cannot be recorded is undone. An empty voterUUID records and logs that the action
+// happened without naming the voter, for actions whose time must not be linked to them.
+func audited(r *http.Request, conn *sqlite.Conn, action string, voterUUID string, perform func() error) (err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	if err = perform(); err != nil {
+		return err
+	}
+	claims, _ := session.FromContext(r.Context())
+	if !claims.IsCentralAuthority {
+		return nil
+	}
+	var target any
+	if voterUUID != "" {
+		target = voterUUID
+	}
+	err = sqlitex.Execute(conn, `INSERT INTO audit_log (actor_uuid, action, target_uuid) VALUES (?, ?, ?);`, &sqlitex.ExecOptions{
+		Args: []any{claims.UUID, action, target},
+	})
+	if err == nil && voterUUID != "" {
+		log.Printf("Central authority %s performed %s on behalf of %s\n", claims.UUID, action, voterUUID)
+	} else if err == nil {
+		log.Printf("Central authority %s performed %s on behalf of a voter\n", claims.UUID, action)
+	}
+	return err
+}
+
 // bodyClose closes the request body and logs any errors.
 func bodyClose(Body io.ReadCloser) {
 	err := Body.Close()
@@ -82,12 +200,15 @@ func (s *Server) handleAuthLogin() http.HandlerFunc {
 	}
 	type response struct {
 		Success            bool   `json:"success"`
+		UUID               string `json:"uuid"`
 		Email              string `json:"email"`
 		Constituency       string `json:"constituency"`
 		IsCentralAuthority bool   `json:"isCentralAuthority"`
 		HasPublicKey       bool   `json:"hasPublicKey"`
 		HasVoted           bool   `json:"hasVoted"`
 		HasDefaultPassword bool   `json:"hasDefaultPassword"`
+		Token              string `json:"token"`
+		TokenExpiresAt     int64  `json:"tokenExpiresAt"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -111,6 +232,7 @@ func (s *Server) handleAuthLogin() http.HandlerFunc {
 			return
 		}
 
+		var uuid string
 		var email string
 		var hash string
 		var constituency string
@@ -119,9 +241,15 @@ func (s *Server) handleAuthLogin() http.HandlerFunc {
 		var hasVoted bool
 		var hasDefaultPassword bool
 
-		query := `SELECT password, constituency, is_central_authority, public_key, has_voted, has_default_password, email FROM users WHERE email = ?;`
+		// hasVoted refers to the default election, as logging in is not scoped to an election.
+		query := `
+			SELECT password, constituency, is_central_authority, public_key,
+				COALESCE((SELECT has_voted FROM election_voters WHERE election_id = ?1 AND user_uuid = uuid), FALSE)
+					OR EXISTS (SELECT 1 FROM participation_queue WHERE election_id = ?1 AND user_uuid = uuid),
+				has_default_password, email, uuid
+			FROM users WHERE email = ?2;`
 		err := sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
-			Args: []any{req.Email},
+			Args: []any{election.DefaultID, req.Email},
 			ResultFunc: func(stmt *sqlite.Stmt) error {
 				hash = stmt.ColumnText(0)
 				constituency = stmt.ColumnText(1)
@@ -130,6 +258,7 @@ func (s *Server) handleAuthLogin() http.HandlerFunc {
 				hasVoted = stmt.ColumnBool(4)
 				hasDefaultPassword = stmt.ColumnBool(5)
 				email = stmt.ColumnText(6)
+				uuid = stmt.ColumnText(7)
 				return nil
 			},
 		})
@@ -147,18 +276,31 @@ func (s *Server) handleAuthLogin() http.HandlerFunc {
 		// Verify password.
 		if match, err := argon2id.ComparePasswordAndHash(req.Password, hash); err != nil {
 			log.Println("Error comparing password and hash : " + err.Error())
+			http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+			return
 		} else if !match {
 			http.Error(w, "Invalid email or password", http.StatusUnauthorized)
 			return
 		}
 
+		// Issue a session token, to be sent as "Authorization: Bearer <token>".
+		token, claims, err := s.Sessions.Mint(uuid, email, isCentralAuthority)
+		if err != nil {
+			http.Error(w, "Error creating session token", http.StatusInternalServerError)
+			return
+		}
+
 		jsonResponse, err := json.Marshal(response{
+			Success:            true,
+			UUID:               uuid,
 			Email:              req.Email,
 			Constituency:       constituency,
 			IsCentralAuthority: isCentralAuthority,
 			HasPublicKey:       publicKey != "",
 			HasVoted:           hasVoted,
 			HasDefaultPassword: hasDefaultPassword,
+			Token:              token,
+			TokenExpiresAt:     claims.ExpiresAt,
 		})
 		if err != nil {
 			http.Error(w, "Error converting response to JSON", http.StatusInternalServerError)
@@ -169,6 +311,8 @@ func (s *Server) handleAuthLogin() http.HandlerFunc {
 	}
 }
 
+// handleAuthResetPassword resets the password of a voter to the default password.
+// Only a central authority may reset a password, and every reset is recorded in the audit log, see audited.
 func (s *Server) handleAuthResetPassword() http.HandlerFunc {
 	type request struct {
 		Email string `json:"email"`
@@ -192,28 +336,21 @@ func (s *Server) handleAuthResetPassword() http.HandlerFunc {
 			return
 		}
 
-		// Check if the user exists, and the user is not a central authority.
-		// Central authority should not reset their password from the frontend interface.
-		var email string
-		query := `SELECT email FROM users WHERE email = ? AND is_central_authority = FALSE;`
-		err := sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
-			Args: []any{req.Email},
-			ResultFunc: func(stmt *sqlite.Stmt) error {
-				email = stmt.ColumnText(0)
-				return nil
-			},
-		})
-		if err != nil {
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		if email == "" || email != req.Email {
-			http.Error(w, "Invalid email", http.StatusBadRequest)
+		// Central authorities cannot be reset, as actingVoter only finds voters.
+		uuid, ok := actingVoter(w, r, conn, req.Email)
+		if !ok {
 			return
 		}
 
-		// Use the helper function to update the user's password.
-		err = helperUpdatePassword(conn, req.Email, "password")
+		// Use the helper function to update the user's password, and have them change it at next login.
+		err := audited(r, conn, "reset-password", uuid, func() error {
+			if err := helperUpdatePassword(conn, uuid, "password"); err != nil {
+				return err
+			}
+			return sqlitex.Execute(conn, `UPDATE users SET has_default_password = TRUE WHERE uuid = ?;`, &sqlitex.ExecOptions{
+				Args: []any{uuid},
+			})
+		})
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -228,9 +365,9 @@ func (s *Server) handleAuthResetPassword() http.HandlerFunc {
 	}
 }
 
+// handleAuthUpdatePassword changes the password of the caller, as given by their session token.
 func (s *Server) handleAuthUpdatePassword() http.HandlerFunc {
 	type request struct {
-		Email    string `json:"email"`
 		Password string `json:"password"`
 	}
 	type response struct {
@@ -242,6 +379,11 @@ func (s *Server) handleAuthUpdatePassword() http.HandlerFunc {
 			return
 		}
 		defer bodyClose(r.Body)
+		claims, ok := session.FromContext(r.Context())
+		if !ok {
+			http.Error(w, "Missing session token", http.StatusUnauthorized)
+			return
+		}
 		conn := s.Database.Get(r.Context())
 		defer s.Database.Put(conn)
 
@@ -251,17 +393,21 @@ func (s *Server) handleAuthUpdatePassword() http.HandlerFunc {
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
+		if req.Password == "" {
+			http.Error(w, "Missing password parameter", http.StatusBadRequest)
+			return
+		}
 
 		// Use the helper function to update the user's password.
-		err := helperUpdatePassword(conn, req.Email, req.Password)
+		err := helperUpdatePassword(conn, claims.UUID, req.Password)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		// Set the has_default_password field to false.
-		err = sqlitex.Execute(conn, `UPDATE users SET has_default_password = FALSE WHERE email = ?;`, &sqlitex.ExecOptions{
-			Args: []any{req.Email},
+		err = sqlitex.Execute(conn, `UPDATE users SET has_default_password = FALSE WHERE uuid = ?;`, &sqlitex.ExecOptions{
+			Args: []any{claims.UUID},
 		})
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -277,11 +423,8 @@ func (s *Server) handleAuthUpdatePassword() http.HandlerFunc {
 	}
 }
 
-func helperUpdatePassword(conn *sqlite.Conn, email string, newPassword string) error {
-	// Validate email string (mitigates SQL injection).
-	if _, err := mail.ParseAddress(email); err != nil {
-		return err
-	}
+// helperUpdatePassword hashes a new password and stores it for the user with the given uuid.
+func helperUpdatePassword(conn *sqlite.Conn, uuid string, newPassword string) error {
 
 	// Hash the password.
 	newHash, err := argon2id.CreateHash(newPassword, argon2id.DefaultParams)
@@ -290,9 +433,9 @@ func helperUpdatePassword(conn *sqlite.Conn, email string, newPassword string) e
 	}
 
 	// Update the user's password.
-	query := `UPDATE users SET password = ? WHERE email = ?;`
+	query := `UPDATE users SET password = ? WHERE uuid = ?;`
 	return sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
-		Args: []any{newHash, email},
+		Args: []any{newHash, uuid},
 	})
 }
 
@@ -300,32 +443,92 @@ func helperUpdatePassword(conn *sqlite.Conn, email string, newPassword string) e
 // |                                   Admin and Voter Handlers                                   |
 // +----------------------------------------------------------------------------------------------+
 
+// handleIsEndOfElection reports whether voting in the election in the request context is over.
+// It is kept for the frontend, see handleGetElectionStatus for the full lifecycle status.
 func (s *Server) handleIsEndOfElection() http.HandlerFunc {
-	query := `SELECT EXISTS (SELECT 1 FROM is_end_of_election);`
-	var response string
+	return func(w http.ResponseWriter, r *http.Request) {
+		e, _ := election.FromContext(r.Context())
+		if e.Status.Ended() {
+			respondPlainText(&w, "true")
+		} else {
+			respondPlainText(&w, "false")
+		}
+	}
+}
+
+// handleVerifySignature verifies a linkable ring signature, and returns its key image.
+// Two valid signatures with the same key image were made by the same private key.
+func (s *Server) handleVerifySignature() http.HandlerFunc {
+	type request struct {
+		FoldedPublicKeys string `json:"foldedPublicKeys"`
+		Message          string `json:"message"`
+		Signature        string `json:"signature"`
+	}
+	type response struct {
+		Valid    bool   `json:"valid"`
+		KeyImage string `json:"keyImage,omitempty"`
+		Reason   string `json:"reason,omitempty"`
+	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		conn := s.Database.Get(r.Context())
-		defer s.Database.Put(conn)
+		if !isHeaderJSON(w, r) {
+			return
+		}
+		defer bodyClose(r.Body)
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Error parsing JSON request body", http.StatusBadRequest)
+			return
+		}
 
-		var isEndOfElection bool
-		err := sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
-			ResultFunc: func(stmt *sqlite.Stmt) error {
-				isEndOfElection = stmt.ColumnBool(0)
-				return nil
-			},
-		})
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		// Validate required parameters.
+		// foldedPublicKeys is optional, and defaults to the current ring of the ballot's constituency.
+		if req.Message == "" {
+			http.Error(w, "Missing message parameter", http.StatusBadRequest)
+			return
+		}
+		if req.Signature == "" {
+			http.Error(w, "Missing signature parameter", http.StatusBadRequest)
 			return
 		}
 
-		if isEndOfElection {
-			response = "true"
+		e, _ := election.FromContext(r.Context())
+		if req.FoldedPublicKeys == "" {
+			var constituency string
+			if choice, err := ballot.Decode([]byte(req.Message)); err == nil {
+				constituency = choice.Constituency
+			}
+			conn := s.Database.Get(r.Context())
+			current, published, err := currentRing(conn, e, constituency)
+			s.Database.Put(conn)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !published {
+				http.Error(w, "No ring has been published for this ballot, send foldedPublicKeys", http.StatusConflict)
+				return
+			}
+			req.FoldedPublicKeys = current.FoldedPublicKeys
+		}
+
+		res := response{Valid: true}
+		status := client.VerifySignature([]byte(req.FoldedPublicKeys), []byte(req.Signature), []byte(req.Message), election.CaseIdentifier(e.ID))
+		if status != ring.Success {
+			res = response{Valid: false, Reason: ring.ErrorMessages[status]}
+		} else if status, keyImage := client.SignatureKeyImage([]byte(req.Signature), false); status != ring.Success {
+			// A signature without a key image cannot be linked to others, so it could not be counted.
+			res = response{Valid: false, Reason: ring.ErrorMessages[status]}
 		} else {
-			response = "false"
+			res.KeyImage = string(keyImage)
+		}
+
+		jsonResponse, err := json.Marshal(res)
+		if err != nil {
+			http.Error(w, "Error converting response to JSON", http.StatusInternalServerError)
+			return
 		}
-		respondPlainText(&w, response)
+		respondJSON(&w, jsonResponse)
 	}
 }
 
@@ -333,26 +536,31 @@ func (s *Server) handleIsEndOfElection() http.HandlerFunc {
 // |                                        Admin Handlers                                        |
 // +----------------------------------------------------------------------------------------------+
 
+// handleAdminGetUsers returns the voter roll of the election in the request context.
 func (s *Server) handleAdminGetUsers() http.HandlerFunc {
 	const query = `
 		SELECT json_group_array(json_object(
-			'email', email,
-			'firstName', first_name,
-			'lastName', last_name,
-			'constituency', constituency,
-			'publicKey', public_key,
-			'privateKey', private_key,
-			'hasVoted', has_voted
+			'email', u.email,
+			'firstName', u.first_name,
+			'lastName', u.last_name,
+			'constituency', u.constituency,
+			'publicKey', u.public_key,
+			'privateKey', u.private_key,
+			'hasVoted', v.has_voted
 		)) as result
-		FROM users
-		WHERE is_central_authority = FALSE
-		ORDER BY (public_key != '') DESC, rowid;`
+		FROM users u
+		JOIN election_voters v ON v.user_uuid = u.uuid
+		WHERE v.election_id = ? AND u.is_central_authority = FALSE
+		ORDER BY (u.public_key != '') DESC, u.rowid;`
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		conn := s.Database.Get(r.Context())
 		defer s.Database.Put(conn)
 
-		jsonResponse, err := sqlitex.ResultText(conn.Prep(query))
+		e, _ := election.FromContext(r.Context())
+		stmt := conn.Prep(query)
+		stmt.BindInt64(1, e.ID)
+		jsonResponse, err := sqlitex.ResultText(stmt)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -362,39 +570,75 @@ func (s *Server) handleAdminGetUsers() http.HandlerFunc {
 	}
 }
 
-func (s *Server) handleAdminPutFoldedPublicKeys() http.HandlerFunc {
+// handleAdminGetAuditLog returns the actions central authorities performed on behalf of voters.
+func (s *Server) handleAdminGetAuditLog() http.HandlerFunc {
+	// json_group_array keeps the order of the rows it aggregates, which is only defined by a subquery.
+	const query = `
+		SELECT json_group_array(json_object(
+			'id', id,
+			'actor', actor,
+			'action', action,
+			'target', target,
+			'createdAt', created_at
+		)) as result
+		FROM (
+			SELECT a.id, actor.email AS actor, a.action, target.email AS target, a.created_at
+			FROM audit_log a
+			LEFT JOIN users actor ON actor.uuid = a.actor_uuid
+			LEFT JOIN users target ON target.uuid = a.target_uuid
+			ORDER BY a.id
+		);`
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		conn := s.Database.Get(r.Context())
 		defer s.Database.Put(conn)
 
-		// Store the folded public keys in the blockchain.
-		message, err := foldpub.PutFoldedPublicKeys(conn)
+		jsonResponse, err := sqlitex.ResultText(conn.Prep(query))
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		respondPlainText(&w, message)
+
+		respondJSON(&w, jsonResponse)
 	}
 }
 
-func (s *Server) handleAdminAnnounceResult() http.HandlerFunc {
-	query := `INSERT INTO is_end_of_election  VALUES (1);`
-	expected := "sqlite: step: constraint failed: UNIQUE constraint failed: is_end_of_election.is_end_of_election"
+// handleAdminPutFoldedPublicKeys submits a job that folds the public keys on the voter roll of the election
+// in the request context, publishes them to the ledger, and records them as the election's current rings
+// (see handleGetCurrentRing). There is one ring for the election, or one per constituency, depending on the
+// election's ring mode. The job's result lists the published rings, see handleGetJob.
+func (s *Server) handleAdminPutFoldedPublicKeys() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		e, _ := election.FromContext(r.Context())
+		j, err := s.submitJob(jobFoldRings, e.ID, func(ctx context.Context, progress func(jobProgress)) (any, error) {
+			return s.publishRings(ctx, e, progress)
+		})
+		respondSubmittedJob(&w, j, err)
+	}
+}
 
+// handleAdminAnnounceResult stops the voting process of the election in the request context.
+// It is a shorthand for moving the election from voting to closed, see handleAdminTransitionElection.
+// It is served on POST, and on GET as a deprecated alias for the frontend, which still calls it with a link.
+func (s *Server) handleAdminAnnounceResult() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		conn := s.Database.Get(r.Context())
 		defer s.Database.Put(conn)
 
-		err := sqlitex.Execute(conn, query, nil)
-		if err != nil {
-			if err.Error() == expected {
-				respondPlainText(&w, "Already inserted into is_end_of_election, not inserting again")
-			} else {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-			}
+		e, _ := election.FromContext(r.Context())
+		if e.Status.Ended() {
+			respondPlainText(&w, "Election has already been closed")
+			return
+		}
+		err := election.Transition(conn, e.ID, e.Status, election.Closed)
+		if errors.Is(err, election.ErrInvalidTransition) || errors.Is(err, election.ErrStatusChanged) {
+			http.Error(w, "Unable to close the election from the '"+string(e.Status)+"' status", http.StatusConflict)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		respondPlainText(&w, "Successfully inserted into is_end_of_election")
+		respondPlainText(&w, "Successfully closed the election")
 	}
 }
 
@@ -402,7 +646,8 @@ func (s *Server) handleAdminAnnounceResult() http.HandlerFunc {
 // |                                        Voter Handlers                                        |
 // +----------------------------------------------------------------------------------------------+
 
-// handleVoterGenerateKeys generates a private key and a public key.
+// handleVoterGenerateKeys generates a private key and a public key (for simulation purposes).
+// In production, it is refused, since a private key must never be sent over the wire.
 func (s *Server) handleVoterGenerateKeys() http.HandlerFunc {
 	type response struct {
 		PublicKey  string `json:"publicKey"`
@@ -410,6 +655,10 @@ func (s *Server) handleVoterGenerateKeys() http.HandlerFunc {
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.isSimulation() {
+			http.Error(w, "Keys are generated on the voter's device in production", http.StatusForbidden)
+			return
+		}
 		status, privateKey := client.GeneratePrivateKey("prime256v1", "PEM")
 		if status != ring.Success {
 			http.Error(w, ring.ErrorMessages[status], http.StatusInternalServerError)
@@ -434,7 +683,9 @@ func (s *Server) handleVoterGenerateKeys() http.HandlerFunc {
 	}
 }
 
-// handleVoterSign creates a linkable ring signature.
+// handleVoterSign creates a linkable ring signature over a ballot, see internal/ballot.
+// It needs the voter's private key, so it is only available in simulations: in production,
+// ballots are signed on the voter's device.
 func (s *Server) handleVoterSign() http.HandlerFunc {
 	type request struct {
 		FoldedPublicKeys  string `json:"foldedPublicKeys"`
@@ -446,6 +697,10 @@ func (s *Server) handleVoterSign() http.HandlerFunc {
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.isSimulation() {
+			http.Error(w, "Ballots are signed on the voter's device in production", http.StatusForbidden)
+			return
+		}
 		if r.Header.Get("Content-Type") != "application/json" {
 			http.Error(w, "Please send a 'Content-Type' of 'application/json'", http.StatusBadRequest)
 			return
@@ -462,10 +717,7 @@ func (s *Server) handleVoterSign() http.HandlerFunc {
 		}
 
 		// Validate required parameters.
-		if req.FoldedPublicKeys == "" {
-			http.Error(w, "Missing foldedPublicKeys parameter", http.StatusBadRequest)
-			return
-		}
+		// foldedPublicKeys is optional, and defaults to the current ring of the ballot's constituency.
 		if req.PrivateKeyContent == "" {
 			http.Error(w, "Missing privateKeyContent parameter", http.StatusBadRequest)
 			return
@@ -475,13 +727,51 @@ func (s *Server) handleVoterSign() http.HandlerFunc {
 			return
 		}
 
+		// Only sign canonically encoded ballots for a candidate standing in the ballot's constituency.
+		e, _ := election.FromContext(r.Context())
+		choice, err := ballot.Decode([]byte(req.Message))
+		if err != nil {
+			http.Error(w, "The message must be a canonically encoded ballot", http.StatusBadRequest)
+			return
+		}
+		conn := s.Database.Get(r.Context())
+		candidates, err := ballot.ListCandidates(conn, e.ID)
+		if err != nil {
+			s.Database.Put(conn)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		current, published, err := currentRing(conn, e, choice.Constituency)
+		s.Database.Put(conn)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ballot.Eligible(e.ID, candidates)(choice) {
+			http.Error(w, "The ballot is not a valid choice in this election", http.StatusBadRequest)
+			return
+		}
+
+		// Pick the current ring of the ballot, the election's or its constituency's, which is the only ring counted for it.
+		// A ring given by the client is only used as is until one is published.
+		if req.FoldedPublicKeys == "" {
+			if !published {
+				http.Error(w, "No ring has been published for this ballot, see /ring/current", http.StatusConflict)
+				return
+			}
+			req.FoldedPublicKeys = current.FoldedPublicKeys
+		} else if published && req.FoldedPublicKeys != current.FoldedPublicKeys {
+			http.Error(w, "foldedPublicKeys is not the current ring of this ballot, see /ring/current", http.StatusBadRequest)
+			return
+		}
+
 		// Convert JSON fields to byte arrays.
 		foldedPublicKeys := []byte(req.FoldedPublicKeys)
 		privateKeyContent := []byte(req.PrivateKeyContent)
 		message := []byte(req.Message)
 
-		// Sign message. caseIdentifier is empty because there's no multi-round voting.
-		status, signature := client.CreateSignature(foldedPublicKeys, privateKeyContent, message, []byte(""), "PEM")
+		// Sign message.
+		status, signature := client.CreateSignature(foldedPublicKeys, privateKeyContent, message, election.CaseIdentifier(e.ID), "PEM")
 		if status != ring.Success {
 			http.Error(w, ring.ErrorMessages[status], http.StatusInternalServerError)
 			return
@@ -500,7 +790,10 @@ func (s *Server) handleVoterSign() http.HandlerFunc {
 	}
 }
 
-// handleVoterUpdateHasVotedByEmail updates the has_voted database field of a user.
+// handleVoterUpdateHasVotedByEmail records whether the caller has voted,
+// or the voter given by email if the caller is a central authority.
+// A voter who has voted is only marked on the voter roll by the next batch, see markVoted,
+// so that the voter roll cannot be matched with the bulletin by time. Nothing is logged either.
 func (s *Server) handleVoterUpdateHasVotedByEmail() http.HandlerFunc {
 	type request struct {
 		Email    string `json:"email"`
@@ -511,7 +804,6 @@ func (s *Server) handleVoterUpdateHasVotedByEmail() http.HandlerFunc {
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		log.Println("Called: handleVoterUpdateHasVotedByEmail()")
 		if !isHeaderJSON(w, r) {
 			return
 		}
@@ -524,15 +816,37 @@ func (s *Server) handleVoterUpdateHasVotedByEmail() http.HandlerFunc {
 		conn := s.Database.Get(r.Context())
 		defer s.Database.Put(conn)
 
-		// Validate email.
-		if _, err := mail.ParseAddress(req.Email); err != nil {
-			http.Error(w, "Invalid email", http.StatusBadRequest)
+		uuid, ok := actingVoter(w, r, conn, req.Email)
+		if !ok {
+			return
+		}
+
+		// Check the voter is on the voter roll.
+		e, _ := election.FromContext(r.Context())
+		var onRoll bool
+		err := sqlitex.Execute(conn, `SELECT EXISTS (SELECT 1 FROM election_voters WHERE election_id = ? AND user_uuid = ?);`, &sqlitex.ExecOptions{
+			Args: []any{e.ID, uuid},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				onRoll = stmt.ColumnBool(0)
+				return nil
+			},
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !onRoll {
+			http.Error(w, "Voter is not on the voter roll of this election", http.StatusForbidden)
 			return
 		}
 
-		// Update the user's hasVoted field.
-		err := sqlitex.Execute(conn, `UPDATE users SET has_voted = ? WHERE email = ?;`, &sqlitex.ExecOptions{
-			Args: []any{req.HasVoted, req.Email},
+		// Queue a voter who has voted, but unmark one who has not at once: it says nothing about a ballot.
+		// The override is audited without the voter, as it would otherwise record when they voted.
+		err = audited(r, conn, "update-has-voted", "", func() error {
+			if req.HasVoted {
+				return queueVoted(conn, e.ID, uuid)
+			}
+			return unmarkVoted(conn, e.ID, uuid)
 		})
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -548,12 +862,18 @@ func (s *Server) handleVoterUpdateHasVotedByEmail() http.HandlerFunc {
 	}
 }
 
-// handleVoterUpdateKeysByEmail updates the public key and private key of a user.
+// handleVoterUpdateKeysByEmail updates the public key and private key of the caller,
+// or of the voter given by email if the caller is a central authority.
+// The public key must be a prime256v1 key no other voter has registered, along with a signed challenge
+// (see handleVoterGetKeyChallenge) proving possession of its private key. In simulations, a matching
+// private key may be sent instead of the signed challenge, and is stored; in production, it is refused.
 func (s *Server) handleVoterUpdateKeysByEmail() http.HandlerFunc {
 	type request struct {
 		Email      string `json:"email"`
 		PublicKey  string `json:"publicKey"`
-		PrivateKey string `json:"privateKey"`
+		Challenge  string `json:"challenge"`  // Issued by handleVoterGetKeyChallenge
+		Proof      string `json:"proof"`      // Signature of voterkey.ProofMessage, see voterkey.VerifyProof
+		PrivateKey string `json:"privateKey"` // Only accepted in simulations
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -570,36 +890,104 @@ func (s *Server) handleVoterUpdateKeysByEmail() http.HandlerFunc {
 		conn := s.Database.Get(r.Context())
 		defer s.Database.Put(conn)
 
-		// Validate email.
-		if _, err := mail.ParseAddress(req.Email); err != nil {
-			http.Error(w, "Invalid email", http.StatusBadRequest)
-			return
-		}
-
 		// Store the public key.
 		if req.PublicKey == "" {
 			http.Error(w, "Missing publicKey parameter", http.StatusBadRequest)
 			return
 		}
-		if err := sqlitex.Execute(conn, "UPDATE users SET public_key = ? WHERE email = ?",
-			&sqlitex.ExecOptions{
-				Args: []any{req.PublicKey, req.Email},
-			},
-		); err != nil {
+		uuid, ok := actingVoter(w, r, conn, req.Email)
+		if !ok {
+			return
+		}
+
+		// Reject keys that could not be folded into a ring, or that would poison it.
+		publicKey, err := voterkey.ParsePublicKey(req.PublicKey)
+		if errors.Is(err, voterkey.ErrWrongCurve) {
+			http.Error(w, "Invalid publicKey parameter, expected a key on the prime256v1 curve", http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, "Invalid publicKey parameter, expected a PEM encoded ECDSA public key", http.StatusBadRequest)
+			return
+		}
+		publicKeyPEM, err := voterkey.EncodePublicKey(publicKey)
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if !s.isSimulation() && req.PrivateKey != "" {
+			http.Error(w, "Private keys are not accepted in production", http.StatusBadRequest)
+			return
+		}
 
-		// Store the private key (for simulation purposes).
-		if req.PrivateKey != "" {
-			if err := sqlitex.Execute(conn, "UPDATE users SET private_key = ? WHERE email = ?",
+		// Public keys may not change once a ring containing them is in use.
+		e, _ := election.FromContext(r.Context())
+		if !e.Status.AcceptsKeys() {
+			http.Error(w, "Public keys are frozen for this election", http.StatusConflict)
+			return
+		}
+		if frozen, err := election.KeysFrozenFor(conn, uuid); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		} else if frozen {
+			http.Error(w, "Public keys are frozen by another election the voter is enrolled in", http.StatusConflict)
+			return
+		}
+		if inUse, err := voterkey.InUse(conn, publicKeyPEM, uuid); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		} else if inUse {
+			http.Error(w, "This public key is already registered by another voter", http.StatusConflict)
+			return
+		}
+
+		// The private key never leaves the voter's device, so they prove they hold it instead.
+		// The challenge is consumed along with the update, so that it is only used up if the key is stored.
+		proven := s.isSimulation() && req.Proof == "" && req.PrivateKey != ""
+		if proven && !voterkey.MatchesPrivateKey(publicKey, req.PrivateKey) {
+			http.Error(w, "Invalid privateKey parameter, it does not match the public key", http.StatusBadRequest)
+			return
+		}
+
+		err = audited(r, conn, "update-keys", uuid, func() error {
+			if !proven {
+				if err := voterkey.ConsumeChallenge(conn, uuid, req.Challenge); err != nil {
+					return err
+				}
+				if err := voterkey.VerifyProof(publicKey, voterkey.ProofMessage(uuid, req.Challenge), req.Proof); err != nil {
+					return err
+				}
+			}
+			if err := sqlitex.Execute(conn, "UPDATE users SET public_key = ? WHERE uuid = ?",
 				&sqlitex.ExecOptions{
-					Args: []any{req.PrivateKey, req.Email},
+					Args: []any{publicKeyPEM, uuid},
 				},
 			); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
+				return err
+			}
+
+			// Store the private key (for simulation purposes).
+			if req.PrivateKey == "" {
+				return nil
 			}
+			return sqlitex.Execute(conn, "UPDATE users SET private_key = ? WHERE uuid = ?",
+				&sqlitex.ExecOptions{
+					Args: []any{req.PrivateKey, uuid},
+				},
+			)
+		})
+		if errors.Is(err, voterkey.ErrChallenge) {
+			http.Error(w, "Invalid challenge parameter, request a new one from /voter/keys/challenge", http.StatusBadRequest)
+			return
+		} else if errors.Is(err, voterkey.ErrInvalidProof) {
+			http.Error(w, "Invalid proof parameter, expected a signature of the challenge's message with the private key", http.StatusBadRequest)
+			return
+		} else if sqlite.ErrCode(err) == sqlite.ResultConstraintUnique {
+			// Another voter registered the same key since InUse was checked.
+			http.Error(w, "This public key is already registered by another voter", http.StatusConflict)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
 
 		// Return a JSON response of {success: true}
@@ -613,7 +1001,51 @@ func (s *Server) handleVoterUpdateKeysByEmail() http.HandlerFunc {
 	}
 }
 
-// handleVoterGetPrivateKeyByEmail returns the private key of a user (for simulation purposes).
+// handleVoterGetKeyChallenge issues a challenge for the caller, or for the voter given by email
+// if the caller is a central authority, to sign when registering a public key (see handleVoterUpdateKeysByEmail).
+func (s *Server) handleVoterGetKeyChallenge() http.HandlerFunc {
+	type request struct {
+		Email string `json:"email"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isHeaderJSON(w, r) {
+			return
+		}
+		defer bodyClose(r.Body)
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Error parsing JSON request body", http.StatusBadRequest)
+			return
+		}
+		conn := s.Database.Get(r.Context())
+		defer s.Database.Put(conn)
+
+		uuid, ok := actingVoter(w, r, conn, req.Email)
+		if !ok {
+			return
+		}
+		var challenge voterkey.Challenge
+		err := audited(r, conn, "get-key-challenge", uuid, func() (err error) {
+			challenge, err = voterkey.IssueChallenge(conn, uuid)
+			return err
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		jsonResponse, err := json.Marshal(challenge)
+		if err != nil {
+			http.Error(w, "Error converting response to JSON", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(&w, jsonResponse)
+	}
+}
+
+// handleVoterGetPrivateKeyByEmail returns the private key of the caller (for simulation purposes),
+// or of the voter given by email if the caller is a central authority. It is refused in production.
 func (s *Server) handleVoterGetPrivateKeyByEmail() http.HandlerFunc {
 	type request struct {
 		Email string `json:"email"`
@@ -636,24 +1068,29 @@ func (s *Server) handleVoterGetPrivateKeyByEmail() http.HandlerFunc {
 		conn := s.Database.Get(r.Context())
 		defer s.Database.Put(conn)
 
-		// Validate email.
-		if _, err := mail.ParseAddress(req.Email); err != nil {
-			http.Error(w, "Invalid email", http.StatusBadRequest)
+		if !s.isSimulation() {
+			http.Error(w, "Private keys are not stored in production", http.StatusForbidden)
+			return
+		}
+		uuid, ok := actingVoter(w, r, conn, req.Email)
+		if !ok {
 			return
 		}
 
 		// Get the private key of the user.
 		var privateKey string
-		if err := sqlitex.Execute(conn,
-			"SELECT private_key FROM users WHERE email = ?",
-			&sqlitex.ExecOptions{
-				Args: []any{req.Email},
-				ResultFunc: func(stmt *sqlite.Stmt) error {
-					privateKey = stmt.ColumnText(0)
-					return nil
+		if err := audited(r, conn, "get-private-key", uuid, func() error {
+			return sqlitex.Execute(conn,
+				"SELECT private_key FROM users WHERE uuid = ?",
+				&sqlitex.ExecOptions{
+					Args: []any{uuid},
+					ResultFunc: func(stmt *sqlite.Stmt) error {
+						privateKey = stmt.ColumnText(0)
+						return nil
+					},
 				},
-			},
-		); err != nil {
+			)
+		}); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -0,0 +1,73 @@
+package cmd
+
+// Standard library on top, application and third-party packages below.
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestActingVoter(t *testing.T) {
+	s := newTestServer(t, "simulation-full", 4)
+	admin := login(t, s, "admin@sentinelvote.tech")
+	voter := login(t, s, "user1@sentinelvote.tech")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   map[string]any
+		status int
+	}{
+		{"own private key", http.MethodPost, "/voter/private-key", voter, map[string]any{}, http.StatusOK},
+		{"own private key by email", http.MethodPost, "/voter/private-key", voter, map[string]any{"email": "USER1@sentinelvote.tech"}, http.StatusOK},
+		{"private key of another voter", http.MethodPost, "/voter/private-key", voter, map[string]any{"email": "user2@sentinelvote.tech"}, http.StatusForbidden},
+		{"challenge of another voter", http.MethodPost, "/voter/keys/challenge", voter, map[string]any{"email": "user2@sentinelvote.tech"}, http.StatusForbidden},
+		{"keys of another voter", http.MethodPatch, "/voter/keys", voter, map[string]any{"email": "user2@sentinelvote.tech", "publicKey": "key"}, http.StatusForbidden},
+		{"has voted of another voter", http.MethodPatch, "/voter/has-voted", voter, map[string]any{"email": "user2@sentinelvote.tech", "hasVoted": true}, http.StatusForbidden},
+		{"password of another voter", http.MethodPost, "/login/reset", voter, map[string]any{"email": "user2@sentinelvote.tech"}, http.StatusForbidden},
+		{"without a session", http.MethodPost, "/voter/private-key", "", map[string]any{}, http.StatusUnauthorized},
+		{"central authority without email", http.MethodPost, "/voter/private-key", admin, map[string]any{}, http.StatusBadRequest},
+		{"central authority on an unknown voter", http.MethodPost, "/voter/private-key", admin, map[string]any{"email": "nobody@sentinelvote.tech"}, http.StatusNotFound},
+		{"central authority on a voter", http.MethodPost, "/voter/private-key", admin, map[string]any{"email": "user2@sentinelvote.tech"}, http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if w := do(t, s, test.method, test.path, test.token, test.body); w.Code != test.status {
+				t.Errorf("got status %d, want %d: %s", w.Code, test.status, w.Body.String())
+			}
+		})
+	}
+
+	// A voter gets their own private key, whatever the email says.
+	privateKey := func(token string, email string) string {
+		var res struct {
+			PrivateKey string `json:"privateKey"`
+		}
+		decode(t, do(t, s, http.MethodPost, "/voter/private-key", token, map[string]any{"email": email}), http.StatusOK, &res)
+		return res.PrivateKey
+	}
+	if own, overridden := privateKey(voter, ""), privateKey(admin, "user1@sentinelvote.tech"); own == "" || own != overridden {
+		t.Error("a voter did not get their own private key")
+	}
+	if privateKey(voter, "") == privateKey(admin, "user2@sentinelvote.tech") {
+		t.Error("a voter got the private key of another voter")
+	}
+
+	// Only the overrides of a central authority are audited, each naming its voter.
+	var entries []struct {
+		Actor  string `json:"actor"`
+		Action string `json:"action"`
+		Target string `json:"target"`
+	}
+	decode(t, do(t, s, http.MethodGet, "/admin/audit-log", admin, nil), http.StatusOK, &entries)
+	if len(entries) != 3 {
+		t.Fatalf("got %d audit log entries, want 3: %+v", len(entries), entries)
+	}
+	for _, entry := range entries {
+		if entry.Actor != "admin@sentinelvote.tech" || entry.Action != "get-private-key" || !strings.HasPrefix(entry.Target, "user") {
+			t.Errorf("got audit log entry %+v", entry)
+		}
+	}
+}
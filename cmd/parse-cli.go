@@ -1,14 +1,27 @@
 package cmd
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"os"
 	"regexp"
+	"time"
+
+	"github.com/sentinelvote/backend/internal/foldpub"
+	"github.com/sentinelvote/backend/internal/tally"
 )
 
 type Flags struct {
-	URI        string
-	Schema     string
-	TotalUsers int
+	URI           string
+	Resume        bool
+	Schema        string
+	TotalUsers    int
+	SessionSecret string
+	SessionTTL    time.Duration
+	Ledger        string
+	FabricConfig  string               // Path to a JSON config file of the Fabric connection
+	Fabric        foldpub.FabricConfig // Fabric connection settings given as flags, overriding the config file
 }
 
 func ParseCLI() Flags {
@@ -20,6 +33,12 @@ func ParseCLI() Flags {
 		"Database URI. Use an alphanumeric filename without extension.",
 	)
 
+	resume := flag.Bool(
+		"resume",
+		false,
+		"Reuse the existing database at -uri instead of recreating it. -schema and -users are then ignored.",
+	)
+
 	schema := flag.String(
 		"schema",
 		"production",
@@ -32,6 +51,41 @@ func ParseCLI() Flags {
 		"Number of users to create, a value between 3 and 1000000",
 	)
 
+	sessionSecret := flag.String(
+		"secret",
+		"",
+		"Secret used to sign session tokens. If empty, a random secret is generated on startup.",
+	)
+
+	sessionTTL := flag.Duration(
+		"session-ttl",
+		12*time.Hour,
+		"Lifetime of a session token issued by /login, e.g. '30m' or '12h'.",
+	)
+
+	ledger := flag.String(
+		"ledger",
+		"fabric",
+		"Ledger backend. Use 'fabric' for the blockchain, or 'sqlite' for an embedded ledger at public/ledger.db.",
+	)
+
+	fabricConfig := flag.String(
+		"fabric-config",
+		"",
+		"Path to a JSON file with the Fabric connection settings, see foldpub.FabricConfig. "+
+			"Environment variables (SENTINELVOTE_FABRIC_*) override the file, and the -fabric-* flags override both.",
+	)
+	var fabric foldpub.FabricConfig
+	flag.StringVar(&fabric.URL, "fabric-url", "", "Base URL of the Fablo REST gateway. (default \"http://localhost:8801\")")
+	flag.StringVar(&fabric.Username, "fabric-username", "", "Fabric user enrolled to obtain a token. (default \"admin\")")
+	flag.StringVar(&fabric.Password, "fabric-password", "", "Password of the Fabric user.")
+	flag.StringVar(&fabric.Channel, "fabric-channel", "", "Channel the chaincode is deployed on. (default \"vote-channel\")")
+	flag.StringVar(&fabric.Chaincode, "fabric-chaincode", "", "Name of the deployed chaincode. (default \"SentinelVote\")")
+	flag.StringVar(&fabric.CACert, "fabric-ca-cert", "", "PEM file of the CA that signed the gateway's TLS certificate.")
+	fabricInsecure := flag.Bool("fabric-insecure", false, "Skip verifying the gateway's TLS certificate, for testing only.")
+	flag.DurationVar((*time.Duration)(&fabric.Timeout), "fabric-timeout", 0, "Timeout of a single request to the gateway. (default 10s)")
+	fabricRetries := flag.Int("fabric-retries", 3, "Retries of an idempotent ledger call that failed, with exponential backoff.")
+
 	flag.Parse() // -h and --help is implicitly defined.
 
 	// Validate the database URI.
@@ -51,14 +105,85 @@ func ParseCLI() Flags {
 		*schema = "production"
 	}
 
+	// Only override the Fabric retries and TLS verification given in a config file if their flag is set.
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "fabric-retries":
+			fabric.Retries = fabricRetries
+		case "fabric-insecure":
+			fabric.InsecureSkipVerify = fabricInsecure
+		}
+	})
+
+	// Validate the ledger backend.
+	if *ledger != "fabric" && *ledger != "sqlite" {
+		*ledger = "fabric"
+	}
+
 	// Validate the number of users.
 	if *totalUsers < 3 || *totalUsers > 1_000_000 {
 		*totalUsers = 3
 	}
 
+	// Validate the session lifetime.
+	if *sessionTTL <= 0 {
+		*sessionTTL = 12 * time.Hour
+	}
+
 	return Flags{
-		URI:        *uri,
-		Schema:     *schema,
-		TotalUsers: *totalUsers,
+		URI:           *uri,
+		Resume:        *resume,
+		Schema:        *schema,
+		TotalUsers:    *totalUsers,
+		SessionSecret: *sessionSecret,
+		SessionTTL:    *sessionTTL,
+		Ledger:        *ledger,
+		FabricConfig:  *fabricConfig,
+		Fabric:        fabric,
+	}
+}
+
+// RecountFlags are the flags of the recount command, see Recount.
+type RecountFlags struct {
+	Bulletin   string   // Path to a bulletin archive, or "-" for standard input
+	Rings      []string // Paths to folded public keys, each optionally prefixed with "CONSTITUENCY="
+	Candidates string   // Path to the candidates on the ballot, as returned by /ballot
+	ElectionID int64    // Zero to use the election of the bulletin's entries
+	Policy     tally.Policy
+	JSON       bool
+}
+
+// ParseRecountCLI parses the arguments of the recount command, i.e. those after "recount".
+func ParseRecountCLI(args []string) (RecountFlags, error) {
+	var f RecountFlags
+	flags := flag.NewFlagSet("recount", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s recount -bulletin FILE -ring FILE [-ring CONSTITUENCY=FILE ...] [flags]\n", os.Args[0])
+		fmt.Fprintln(flags.Output(), "Re-verifies every ballot of an exported bulletin (/bulletin/archive) and prints the totals.")
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&f.Bulletin, "bulletin", "", "Path to the bulletin archive (JSON Lines), or '-' for standard input.")
+	flags.Func("ring", "Path to the folded public keys, as published or as returned by /ring/current. "+
+		"Repeat it once per constituency, as CONSTITUENCY=FILE unless the file is from /ring/current.", func(value string) error {
+		f.Rings = append(f.Rings, value)
+		return nil
+	})
+	flags.StringVar(&f.Candidates, "candidates", "", "Path to the candidates on the ballot, as returned by /ballot. "+
+		"If set, ballots for a candidate not standing in their constituency are not counted.")
+	flags.Int64Var(&f.ElectionID, "election", 0, "Election id. (default the election of the bulletin's entries)")
+	policy := flags.String("policy", string(tally.FirstWins), "Which of several linked ballots counts, 'first-wins' or 'last-wins'.")
+	flags.BoolVar(&f.JSON, "json", false, "Print the totals in JSON, in the same format as /results.")
+
+	if err := flags.Parse(args); err != nil {
+		return RecountFlags{}, err
+	}
+	f.Policy = tally.Policy(*policy)
+	if !f.Policy.Valid() {
+		return RecountFlags{}, fmt.Errorf("unknown policy %q, use 'first-wins' or 'last-wins'", *policy)
+	}
+	if f.Bulletin == "" || len(f.Rings) == 0 {
+		flags.Usage()
+		return RecountFlags{}, errors.New("-bulletin and -ring are required")
 	}
+	return f, nil
 }
@@ -0,0 +1,234 @@
+package cmd
+
+// Standard library on top, application and third-party packages below.
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/goccy/go-json"
+	"github.com/sentinelvote/backend/internal/ballot"
+	"github.com/sentinelvote/backend/internal/bulletin"
+	"github.com/sentinelvote/backend/internal/election"
+	"github.com/sentinelvote/backend/internal/foldpub"
+	"github.com/sentinelvote/backend/internal/tally"
+)
+
+// Recount is the entrypoint of the recount command: it counts an exported bulletin (see handleGetBulletinArchive)
+// against published folded public keys, without a server or a database, so that anyone can check the results.
+// Signatures are verified and linked ballots de-duplicated by tally.Count, as by handleAdminTally.
+func Recount(args []string) error {
+	flags, err := ParseRecountCLI(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Read the rings and the candidates.
+	opts := tally.Options{Policy: flags.Policy}
+	rings := map[string][]byte{}
+	ringHashes := map[string]bool{}
+	for _, value := range flags.Rings {
+		constituency, foldedPublicKeys, err := readRing(value)
+		if err != nil {
+			return err
+		}
+		if _, ok := rings[constituency]; ok {
+			return fmt.Errorf("%s: more than one ring for the same constituency", value)
+		}
+		rings[constituency] = foldedPublicKeys
+		ringHashes[foldpub.HashFoldedPublicKeys(foldedPublicKeys)] = true
+	}
+	if _, ok := rings[""]; ok && len(rings) > 1 {
+		return errors.New("give either one ring for the election, or one ring per constituency")
+	} else if ok {
+		opts.FoldedPublicKeys = rings[""]
+	} else {
+		opts.Rings = rings
+	}
+	var candidates []ballot.Candidate
+	if flags.Candidates != "" {
+		if candidates, err = readCandidates(flags.Candidates); err != nil {
+			return err
+		}
+	}
+
+	// Read the bulletin, checking that every entry is of the same election, matches its hash,
+	// and was verified by the server against one of the rings.
+	entries, err := readBulletin(flags.Bulletin)
+	if err != nil {
+		return err
+	}
+	electionID := flags.ElectionID
+	ballots := make([]tally.Ballot, 0, len(entries))
+	mismatches, otherRings := 0, 0
+	for i, entry := range entries {
+		if electionID == 0 {
+			electionID = entry.ElectionID
+		} else if entry.ElectionID != electionID {
+			return fmt.Errorf("entry %d is of election %d, not %d", i+1, entry.ElectionID, electionID)
+		}
+		if bulletin.Hash([]byte(entry.Message), []byte(entry.Signature)) != entry.Hash {
+			mismatches++
+		}
+		if !ringHashes[entry.Ring] {
+			otherRings++
+		}
+		ballots = append(ballots, tally.Ballot{
+			Message:   []byte(entry.Message),
+			Signature: []byte(entry.Signature),
+			Reference: entry.TxID,
+		})
+	}
+	if mismatches > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %d entries do not match their hash, the bulletin may have been altered\n", mismatches)
+	}
+	if otherRings > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %d entries were accepted against a ring other than those given\n", otherRings)
+	}
+
+	// Count.
+	opts.CaseIdentifier = election.CaseIdentifier(electionID)
+	if candidates != nil {
+		opts.Eligible = ballot.Eligible(electionID, candidates)
+	} else {
+		opts.Eligible = func(b ballot.Ballot) bool {
+			return b.Election == electionID && b.Constituency != ""
+		}
+	}
+	result, err := tally.Count(ballots, opts)
+	if err != nil {
+		return err
+	}
+
+	// Without the candidates, list those who received votes by id.
+	if candidates == nil {
+		for id := range result.Candidates {
+			candidates = append(candidates, ballot.Candidate{ID: id})
+		}
+		slices.SortFunc(candidates, func(a, b ballot.Candidate) int { return cmp.Compare(a.ID, b.ID) })
+	}
+	res := newResults(result, candidates)
+	if flags.JSON {
+		encoded, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Println(string(encoded))
+		return err
+	}
+	return printResults(os.Stdout, electionID, res)
+}
+
+// readRing reads the folded public keys given to -ring, either as published or as returned by /ring/current,
+// and returns them along with their constituency.
+func readRing(value string) (string, []byte, error) {
+	constituency, path, found := strings.Cut(value, "=")
+	if !found {
+		constituency, path = "", value
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var snapshot foldpub.Snapshot
+	if err := json.Unmarshal(content, &snapshot); err == nil && snapshot.FoldedPublicKeys != "" {
+		if found && !strings.EqualFold(constituency, snapshot.Constituency) {
+			return "", nil, fmt.Errorf("%s: the ring is of constituency %q, not %q", path, snapshot.Constituency, constituency)
+		}
+		constituency, content = snapshot.Constituency, []byte(snapshot.FoldedPublicKeys)
+	}
+	if _, err := foldpub.RingSize(content); err != nil {
+		return "", nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return strings.ToUpper(constituency), content, nil
+}
+
+// readCandidates reads the candidates given to -candidates, as returned by /ballot.
+func readCandidates(path string) ([]ballot.Candidate, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var b struct {
+		Candidates []ballot.Candidate `json:"candidates"`
+	}
+	if err := json.Unmarshal(content, &b); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return b.Candidates, nil
+}
+
+// readBulletin reads the entries of a bulletin archive, one JSON object per line.
+func readBulletin(path string) ([]bulletin.Entry, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	var entries []bulletin.Entry
+	decoder := json.NewDecoder(r)
+	for {
+		var entry bulletin.Entry
+		if err := decoder.Decode(&entry); errors.Is(err, io.EOF) {
+			return entries, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: entry %d: %w", path, len(entries)+1, err)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// printResults writes the totals of a recount as text.
+func printResults(w io.Writer, electionID int64, res results) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Election\t%d\n", electionID)
+	fmt.Fprintf(tw, "Policy\t%s\n", res.Policy)
+	fmt.Fprintf(tw, "Ballots\t%d\n", res.Total)
+	fmt.Fprintf(tw, "Counted\t%d\n", res.Counted)
+	fmt.Fprintf(tw, "Invalid\t%d\n", res.Invalid)
+	fmt.Fprintf(tw, "Duplicates\t%d\n", res.Duplicates)
+	fmt.Fprintln(tw)
+	printCandidateVotes(tw, res.Candidates)
+
+	constituencies := make([]string, 0, len(res.Constituencies))
+	for constituency := range res.Constituencies {
+		constituencies = append(constituencies, constituency)
+	}
+	slices.Sort(constituencies)
+	label := ""
+	if !res.ConstituenciesVerified {
+		label = " (as claimed by each ballot)"
+	}
+	for _, constituency := range constituencies {
+		fmt.Fprintf(tw, "\n%s%s\n", constituency, label)
+		printCandidateVotes(tw, res.Constituencies[constituency])
+	}
+	return tw.Flush()
+}
+
+// printCandidateVotes writes one line per candidate, with their votes.
+func printCandidateVotes(w io.Writer, candidates []candidateVotes) {
+	for _, c := range candidates {
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("Candidate %d", c.ID)
+		} else if c.Party != "" {
+			name += " (" + c.Party + ")"
+		}
+		fmt.Fprintf(w, "%s\t%d\n", name, c.Votes)
+	}
+}
@@ -0,0 +1,93 @@
+package cmd
+
+// Standard library on top, application and third-party packages below.
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/sentinelvote/backend/internal/ballot"
+	"github.com/sentinelvote/backend/internal/election"
+	"github.com/sentinelvote/backend/internal/tally"
+)
+
+// recount runs the recount command, and returns the totals it prints in JSON.
+func recount(t *testing.T, args ...string) results {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	err = Recount(append(args, "-json"))
+	os.Stdout = stdout
+	_ = w.Close()
+	output, readErr := io.ReadAll(r)
+	_ = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	var res results
+	if err := json.Unmarshal(output, &res); err != nil {
+		t.Fatalf("%v: %s", err, output)
+	}
+	return res
+}
+
+// download writes the body of a response to a file in the test's temporary directory, and returns its path.
+func download(t *testing.T, s *Server, path string, name string) string {
+	t.Helper()
+	w := do(t, s, http.MethodGet, path, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: got status %d: %s", path, w.Code, w.Body.String())
+	}
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, w.Body.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestRecount(t *testing.T) {
+	for _, policy := range []tally.Policy{tally.FirstWins, tally.LastWins} {
+		t.Run(string(policy), func(t *testing.T) {
+			s := newTestServer(t, "simulation-full", 4)
+			admin := login(t, s, "admin@sentinelvote.tech")
+			for _, cast := range []struct {
+				email     string
+				candidate int64
+			}{
+				{"user1@sentinelvote.tech", 1},
+				{"user2@sentinelvote.tech", 2},
+				{"user3@sentinelvote.tech", 1},
+				{"user1@sentinelvote.tech", 2}, // Linked to the first ballot
+			} {
+				castBallot(t, s, cast.email, ballot.Ballot{Election: election.DefaultID, Constituency: "BEDOK", Candidate: cast.candidate})
+			}
+			setStatus(t, s, election.DefaultID, election.Closed)
+			var tallied results
+			decode(t, do(t, s, http.MethodPost, "/admin/tally", admin, map[string]any{"policy": policy}), http.StatusOK, &tallied)
+			var want results
+			decode(t, do(t, s, http.MethodGet, "/admin/results", admin, nil), http.StatusOK, &want)
+			if want.Counted != 3 || want.Duplicates != 1 || !reflect.DeepEqual(tallied, want) {
+				t.Fatalf("got tally %+v and results %+v", tallied, want)
+			}
+
+			bulletinFile := download(t, s, "/bulletin/archive", "bulletin.jsonl")
+			ringFile := download(t, s, "/ring/current", "ring.json")
+			candidatesFile := download(t, s, "/ballot", "ballot.json")
+			got := recount(t, "-bulletin", bulletinFile, "-ring", ringFile, "-candidates", candidatesFile, "-policy", string(policy))
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got recount %+v, want %+v", got, want)
+			}
+		})
+	}
+}
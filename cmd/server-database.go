@@ -9,20 +9,28 @@ import (
 	"path/filepath"
 
 	"github.com/sentinelvote/backend/internal/db"
+	"github.com/sentinelvote/backend/internal/election"
+	"github.com/sentinelvote/backend/internal/foldpub"
 	"zombiezen.com/go/sqlite"
 	"zombiezen.com/go/sqlite/sqlitex"
 )
 
 func (s *Server) database(uri string) error {
-	// Handle existing database files.
+	// Handle existing database files, unless resuming from them.
 	uri = filepath.Join("public", uri)
-	for _, file := range []string{
-		uri,
-		uri + "-shm",
-		uri + "-wal",
-	} {
-		if err := removeDatabaseFileIfExists(file); err != nil {
-			return err
+	_, statErr := os.Stat(uri)
+	resume := s.Resume && statErr == nil
+	if resume {
+		log.Println("Resuming existing database at " + uri)
+	} else {
+		for _, file := range []string{
+			uri,
+			uri + "-shm",
+			uri + "-wal",
+		} {
+			if err := removeDatabaseFileIfExists(file); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -47,6 +55,11 @@ func (s *Server) database(uri string) error {
 		},
 	}); err != nil {
 		return err
+	} else if resume {
+		s.Database = pool
+		conn := s.Database.Get(context.Background())
+		defer s.Database.Put(conn)
+		return s.loadSchema(conn)
 	} else {
 		log.Println("Created new database at " + uri)
 		s.Database = pool
@@ -55,15 +68,102 @@ func (s *Server) database(uri string) error {
 	defer s.Database.Put(conn)
 
 	// Set up schema parameters.
+	var err error
 	if s.Schema == "production" {
-		return db.CreateSchema(conn, db.PRODUCTION, s.TotalUsers)
+		err = s.createSchema(context.Background(), conn, db.PRODUCTION, s.TotalUsers, nil)
 	} else if s.Schema == "simulation" {
-		return db.CreateSchema(conn, db.SIMULATION, s.TotalUsers)
+		err = s.createSchema(context.Background(), conn, db.SIMULATION, s.TotalUsers, nil)
 	} else if s.Schema == "simulation-full" {
-		return db.CreateSchema(conn, db.SIMULATION_FULL, s.TotalUsers)
+		err = s.createSchema(context.Background(), conn, db.SIMULATION_FULL, s.TotalUsers, nil)
 	} else {
-		return fmt.Errorf("invalid schema `%s`", s.Schema)
+		err = fmt.Errorf("invalid schema `%s`", s.Schema)
+	}
+	if err != nil {
+		return err
+	}
+	return s.loadSchema(conn)
+}
+
+// createSchema creates the schema of the database, see db.CreateSchema.
+// A full simulation also publishes the ring of its default election, with publishRings as the fold job does,
+// so that ctx interrupts it and progress reports it, then opens voting, which needs that ring.
+func (s *Server) createSchema(ctx context.Context, conn *sqlite.Conn, purpose int, totalUsers int, progress func(jobProgress)) error {
+	if err := db.CreateSchema(conn, purpose, totalUsers); err != nil {
+		return err
+	}
+	if purpose != db.SIMULATION_FULL {
+		return nil
+	}
+
+	e, _, err := election.Get(conn, election.DefaultID)
+	if err != nil {
+		return err
 	}
+	published, err := s.publishRings(ctx, e, progress)
+	if err != nil {
+		return fmt.Errorf("unable to insert folded public keys into the ledger: %w", err)
+	}
+	for _, snapshot := range published {
+		if snapshot.TxID != "" {
+			log.Println("Successfully inserted folded public keys into the ledger, transaction " + snapshot.TxID + ".")
+		} else {
+			log.Println("Successfully inserted folded public keys into the ledger.")
+		}
+	}
+	return election.Transition(conn, e.ID, election.KeysFrozen, election.Voting)
+}
+
+// loadSchema reads the schema the database was created with, see isSimulation.
+// A resumed database may have been created or reset with another schema than -schema.
+func (s *Server) loadSchema(conn *sqlite.Conn) error {
+	schema, found, err := db.CreatedSchema(conn)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("the database does not record its schema, recreate it without -resume")
+	}
+	s.simulation.Store(schema != "production")
+	return nil
+}
+
+// ledger sets up the ledger backend, either the blockchain or an embedded database.
+// The embedded ledger is recreated along with the database, unless resuming.
+func (s *Server) ledger(flags Flags) error {
+	if flags.Ledger == "fabric" {
+		config, err := foldpub.LoadFabricConfig(flags.FabricConfig)
+		if err != nil {
+			return err
+		}
+		config = config.Merge(flags.Fabric)
+		fabric, err := foldpub.NewFabric(config)
+		if err != nil {
+			return err
+		}
+		log.Printf("Using the Fabric ledger at %s, channel `%s`, chaincode `%s`\n", config.URL, config.Channel, config.Chaincode)
+		s.Ledger = fabric
+		return nil
+	}
+
+	uri := filepath.Join("public", "ledger.db")
+	if _, err := os.Stat(uri); err != nil || !s.Resume {
+		for _, file := range []string{
+			uri,
+			uri + "-shm",
+			uri + "-wal",
+		} {
+			if err := removeDatabaseFileIfExists(file); err != nil {
+				return err
+			}
+		}
+	}
+	ledger, err := foldpub.NewSQLite(uri, 4)
+	if err != nil {
+		return err
+	}
+	log.Println("Using embedded ledger at " + uri)
+	s.Ledger = ledger
+	return nil
 }
 
 func removeDatabaseFileIfExists(filename string) error {
@@ -0,0 +1,152 @@
+package cmd
+
+// Standard library on top, application and third-party packages below.
+import (
+	"context"
+	"fmt"
+
+	"github.com/sentinelvote/backend/internal/election"
+	"github.com/sentinelvote/backend/internal/foldpub"
+	"zombiezen.com/go/sqlite"
+)
+
+// publishedRing is a ring published by publishRings.
+type publishedRing struct {
+	foldpub.Result
+	Constituency string `json:"constituency,omitempty"`
+	Ring         string `json:"ring"` // Hash of the folded public keys
+	Members      int    `json:"members"`
+}
+
+// publishRings folds the rings of an election, one for the election or one per constituency depending on its
+// ring mode, publishes them to the ledger, and records them as the election's current rings.
+// The election must be in keys-frozen, see electionAdminRoutes.
+// Every ring is folded before any is published, so that a ring too small to sign with publishes nothing.
+// progress, if not nil, is called as publishing goes: while reading public keys, then after folding
+// and after publishing each ring.
+func (s *Server) publishRings(ctx context.Context, e election.Election, progress func(jobProgress)) ([]publishedRing, error) {
+	if progress == nil {
+		progress = func(jobProgress) {}
+	}
+
+	// Count the public keys of each ring.
+	conn := s.Database.Get(ctx)
+	if conn == nil {
+		return nil, ctx.Err()
+	}
+	constituencies := []string{""}
+	var err error
+	if e.RingMode == election.RingPerConstituency {
+		constituencies, err = foldpub.Constituencies(conn, e.ID)
+	}
+	counts := make([]int, len(constituencies))
+	total := 0
+	for i, constituency := range constituencies {
+		if err != nil {
+			break
+		}
+		var count int
+		count, err = foldpub.CountPublicKeys(conn, e.ID, constituency)
+		counts[i] = count
+		if err == nil && count < foldpub.MinRingSize {
+			if constituency == "" {
+				constituency = "the election"
+			}
+			err = fmt.Errorf("%w: %s has %d public keys, at least %d are needed", foldpub.ErrRingTooSmall, constituency, count, foldpub.MinRingSize)
+		}
+		total += count
+	}
+	s.Database.Put(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fold every ring.
+	rings := make([][]byte, len(constituencies))
+	read := 0
+	for i, constituency := range constituencies {
+		rings[i], err = foldpub.FoldPublicKeysInPages(ctx, s.Database, e.ID, constituency, func(n int) {
+			progress(jobProgress{Phase: "reading", Done: read + n, Total: total})
+		})
+		if err != nil {
+			return nil, err
+		}
+		read += counts[i]
+		progress(jobProgress{Phase: "folding", Done: i + 1, Total: len(constituencies)})
+	}
+
+	// Rings may only change while keys are frozen, which the election may have left while folding.
+	conn = s.Database.Get(ctx)
+	if conn == nil {
+		return nil, ctx.Err()
+	}
+	current, _, err := election.Get(conn, e.ID)
+	s.Database.Put(conn)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status != election.KeysFrozen || current.RingVersion != e.RingVersion {
+		return nil, fmt.Errorf("%w: the election is now %s, at ring version %d", election.ErrStatusChanged, current.Status, current.RingVersion)
+	}
+
+	// Store the folded public keys in the ledger.
+	published := make([]publishedRing, 0, len(rings))
+	for i, foldedPublicKeys := range rings {
+		if err := ctx.Err(); err != nil {
+			return published, err
+		}
+		result, err := s.Ledger.PutFoldedPublicKeys(ctx, e.ID, constituencies[i], foldedPublicKeys)
+		if err != nil {
+			return published, err
+		}
+		conn := s.Database.Get(ctx)
+		if conn == nil {
+			return published, ctx.Err()
+		}
+		snapshot, err := foldpub.RecordSnapshot(conn, e.ID, constituencies[i], foldedPublicKeys, result.TxID, e.RingVersion)
+		s.Database.Put(conn)
+		if err != nil {
+			return published, err
+		}
+		published = append(published, publishedRing{
+			Result:       result,
+			Constituency: snapshot.Constituency,
+			Ring:         snapshot.Hash,
+			Members:      snapshot.Members,
+		})
+		progress(jobProgress{Phase: "publishing", Done: i + 1, Total: len(rings)})
+	}
+
+	// Voting may be waiting for the rings, see runSchedule.
+	s.wakeScheduler()
+	return published, nil
+}
+
+// ringsPublished reports whether every ring of an election has been published at its current ring version,
+// which voting needs: rings published before registration was last reopened do not count.
+func ringsPublished(conn *sqlite.Conn, e election.Election) (bool, error) {
+	_, published, err := currentRings(conn, e)
+	return published, err
+}
+
+// currentRings returns the current ring of an election, keyed by an empty constituency, or of each of its
+// constituencies, depending on its ring mode. It returns false if any of them has not been published
+// at the election's current ring version.
+func currentRings(conn *sqlite.Conn, e election.Election) (map[string]foldpub.Snapshot, bool, error) {
+	constituencies := []string{""}
+	if e.RingMode == election.RingPerConstituency {
+		var err error
+		if constituencies, err = foldpub.Constituencies(conn, e.ID); err != nil || len(constituencies) == 0 {
+			return nil, false, err
+		}
+	}
+	rings := make(map[string]foldpub.Snapshot, len(constituencies))
+	for _, constituency := range constituencies {
+		snapshot, found, err := foldpub.CurrentSnapshot(conn, e.ID, constituency)
+		if err != nil || !found {
+			return nil, false, err
+		}
+		rings[constituency] = snapshot
+	}
+	return rings, true, nil
+}
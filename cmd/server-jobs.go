@@ -0,0 +1,285 @@
+package cmd
+
+// Standard library on top, application and third-party packages below.
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// jobsSchema is created outside the application's schema, so that jobs survive a database reset,
+// which is itself a job (see handleDevDatabaseReset).
+// progress and result are JSON, and a job without an election has an election_id of 0.
+const jobsSchema = `
+CREATE TABLE IF NOT EXISTS jobs (
+    id          TEXT    PRIMARY KEY NOT NULL,
+    kind        TEXT    NOT NULL,
+    election_id INTEGER NOT NULL DEFAULT 0,
+    status      TEXT    NOT NULL,
+    progress    TEXT    NOT NULL DEFAULT '{}',
+    result      TEXT    NOT NULL DEFAULT 'null',
+    error       TEXT    NOT NULL DEFAULT '',
+    created_at  INTEGER NOT NULL DEFAULT (unixepoch()),
+    updated_at  INTEGER NOT NULL DEFAULT (unixepoch()),
+    finished_at INTEGER
+);`
+
+// Kinds of jobs.
+const (
+	jobDatabaseReset   = "database-reset"
+	jobFoldRings       = "fold-rings"
+	jobBlockchainReset = "blockchain-reset"
+)
+
+// Statuses of a job. A job runs as soon as it is submitted, so it is never queued.
+const (
+	jobRunning   = "running"
+	jobSucceeded = "succeeded"
+	jobFailed    = "failed"
+	jobCancelled = "cancelled"
+)
+
+// errJobRunning is returned by submitJob when a job of the same kind is already running.
+var errJobRunning = errors.New("a job of this kind is already running")
+
+// job is a long-running operation, run in the background by submitJob.
+type job struct {
+	ID         string          `json:"id"`
+	Kind       string          `json:"kind"`
+	ElectionID int64           `json:"electionId,omitempty"`
+	Status     string          `json:"status"`
+	Progress   json.RawMessage `json:"progress"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	UpdatedAt  time.Time       `json:"updatedAt"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+}
+
+// jobProgress is how far a job has got.
+type jobProgress struct {
+	Phase string `json:"phase"`
+	Done  int    `json:"done,omitempty"`
+	Total int    `json:"total,omitempty"`
+}
+
+// jobFunc is the work of a job. It calls progress as it goes, and should return early once ctx is cancelled.
+// Its result is stored as JSON, even if it fails.
+type jobFunc func(ctx context.Context, progress func(jobProgress)) (any, error)
+
+// jobRunner keeps how to cancel the jobs running in this process.
+type jobRunner struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+// prepareJobs creates the jobs table, and fails the jobs left running by a previous process.
+func (s *Server) prepareJobs() error {
+	s.jobs.cancels = map[string]context.CancelFunc{}
+
+	conn := s.Database.Get(context.Background())
+	defer s.Database.Put(conn)
+	if err := sqlitex.ExecScript(conn, jobsSchema); err != nil {
+		return err
+	}
+	return sqlitex.Execute(conn, `
+		UPDATE jobs SET status = ?, error = 'Interrupted by a restart', updated_at = unixepoch(), finished_at = unixepoch()
+		WHERE status = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{jobFailed, jobRunning},
+		})
+}
+
+// submitJob starts a job in the background and returns it. If a job of the same kind is already running
+// for the election, that job is returned instead, along with errJobRunning.
+func (s *Server) submitJob(kind string, electionID int64, run jobFunc) (job, error) {
+	s.jobs.mu.Lock()
+	defer s.jobs.mu.Unlock()
+
+	conn := s.Database.Get(context.Background())
+	defer s.Database.Put(conn)
+
+	var runningID string
+	err := sqlitex.Execute(conn, `SELECT id FROM jobs WHERE kind = ? AND election_id = ? AND status = ? LIMIT 1;`,
+		&sqlitex.ExecOptions{
+			Args: []any{kind, electionID, jobRunning},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				runningID = stmt.ColumnText(0)
+				return nil
+			},
+		})
+	if err != nil {
+		return job{}, err
+	} else if runningID != "" {
+		running, _, err := getJob(conn, runningID)
+		if err != nil {
+			return job{}, err
+		}
+		return running, errJobRunning
+	}
+
+	id := uuid.NewString()
+	err = sqlitex.Execute(conn, `INSERT INTO jobs (id, kind, election_id, status) VALUES (?, ?, ?, ?);`,
+		&sqlitex.ExecOptions{
+			Args: []any{id, kind, electionID, jobRunning},
+		})
+	if err != nil {
+		return job{}, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.jobs.cancels[id] = cancel
+	go s.runJob(ctx, id, run)
+
+	submitted, _, err := getJob(conn, id)
+	return submitted, err
+}
+
+// runJob runs a submitted job, and stores its progress and outcome.
+func (s *Server) runJob(ctx context.Context, id string, run jobFunc) {
+	defer func() {
+		s.jobs.mu.Lock()
+		cancel := s.jobs.cancels[id]
+		delete(s.jobs.cancels, id)
+		s.jobs.mu.Unlock()
+		cancel()
+	}()
+
+	result, err := func() (result any, err error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				err = fmt.Errorf("panic: %v", recovered)
+			}
+		}()
+		return run(ctx, func(p jobProgress) {
+			s.updateJob(`UPDATE jobs SET progress = ?, updated_at = unixepoch() WHERE id = ?;`, marshalJob(id, p), id)
+		})
+	}()
+
+	status, message := jobSucceeded, ""
+	if err != nil && ctx.Err() != nil {
+		status = jobCancelled
+	} else if err != nil {
+		status, message = jobFailed, err.Error()
+		log.Printf("Job %s failed: %s\n", id, message)
+	}
+	s.updateJob(`
+		UPDATE jobs SET status = ?, result = ?, error = ?, updated_at = unixepoch(), finished_at = unixepoch()
+		WHERE id = ?;`,
+		status, marshalJob(id, result), message, id)
+}
+
+// updateJob runs an update of the jobs table. Jobs run in the background, so errors are only logged.
+func (s *Server) updateJob(query string, args ...any) {
+	conn := s.Database.Get(context.Background())
+	defer s.Database.Put(conn)
+	if err := sqlitex.Execute(conn, query, &sqlitex.ExecOptions{Args: args}); err != nil {
+		log.Println("Error updating job: " + err.Error())
+	}
+}
+
+// marshalJob converts the progress or result of a job to JSON, or null if it cannot be converted.
+func marshalJob(id string, value any) string {
+	text, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("Error converting job %s to JSON: %s\n", id, err)
+		return "null"
+	}
+	return string(text)
+}
+
+// cancelJob cancels a job running in this process, and reports whether there was one.
+// The job stops at its next opportunity, see jobFunc.
+func (s *Server) cancelJob(id string) bool {
+	s.jobs.mu.Lock()
+	defer s.jobs.mu.Unlock()
+
+	cancel, ok := s.jobs.cancels[id]
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+const jobColumns = `id, kind, election_id, status, progress, result, error, created_at, updated_at, finished_at`
+
+// getJob returns a job, and false if it does not exist.
+func getJob(conn *sqlite.Conn, id string) (job, bool, error) {
+	var j job
+	var found bool
+	err := sqlitex.Execute(conn, `SELECT `+jobColumns+` FROM jobs WHERE id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{id},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				j, found = scanJob(stmt), true
+				return nil
+			},
+		})
+	return j, found, err
+}
+
+// latestJob returns the newest job of a kind for an election, and false if there is none.
+func latestJob(conn *sqlite.Conn, kind string, electionID int64) (job, bool, error) {
+	var j job
+	var found bool
+	err := sqlitex.Execute(conn, `
+		SELECT `+jobColumns+` FROM jobs
+		WHERE kind = ? AND election_id = ?
+		ORDER BY created_at DESC, rowid DESC
+		LIMIT 1;`,
+		&sqlitex.ExecOptions{
+			Args: []any{kind, electionID},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				j, found = scanJob(stmt), true
+				return nil
+			},
+		})
+	return j, found, err
+}
+
+// listJobs returns the latest jobs, newest first, optionally of a single kind.
+func listJobs(conn *sqlite.Conn, kind string, limit int) ([]job, error) {
+	jobs := []job{}
+	err := sqlitex.Execute(conn, `
+		SELECT `+jobColumns+` FROM jobs
+		WHERE ?1 = '' OR kind = ?1
+		ORDER BY created_at DESC, rowid DESC
+		LIMIT ?2;`,
+		&sqlitex.ExecOptions{
+			Args: []any{kind, limit},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				jobs = append(jobs, scanJob(stmt))
+				return nil
+			},
+		})
+	return jobs, err
+}
+
+// scanJob reads a job selected with jobColumns.
+func scanJob(stmt *sqlite.Stmt) job {
+	j := job{
+		ID:         stmt.ColumnText(0),
+		Kind:       stmt.ColumnText(1),
+		ElectionID: stmt.ColumnInt64(2),
+		Status:     stmt.ColumnText(3),
+		Progress:   json.RawMessage(stmt.ColumnText(4)),
+		Error:      stmt.ColumnText(6),
+		CreatedAt:  time.Unix(stmt.ColumnInt64(7), 0).UTC(),
+		UpdatedAt:  time.Unix(stmt.ColumnInt64(8), 0).UTC(),
+	}
+	if result := stmt.ColumnText(5); result != "null" {
+		j.Result = json.RawMessage(result)
+	}
+	if stmt.ColumnType(9) != sqlite.TypeNull {
+		finishedAt := time.Unix(stmt.ColumnInt64(9), 0).UTC()
+		j.FinishedAt = &finishedAt
+	}
+	return j
+}
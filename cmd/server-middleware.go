@@ -2,17 +2,23 @@ package cmd
 
 // Standard library on top, third-party packages below.
 import (
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/sentinelvote/backend/internal/election"
+	"github.com/sentinelvote/backend/internal/session"
 )
 
 //goland:noinspection HttpUrlsUsage
 func (s *Server) middleware() {
-	s.Router.Use(middleware.Logger)
+	s.Router.Use(quietLogger)
 	s.Router.Use(middleware.Recoverer)
-	s.Router.Use(middleware.NoCache)
+	s.Router.Use(noCache)
 	s.Router.Use(middleware.Timeout(120 * time.Second))
 	s.Router.Use(middleware.StripSlashes)
 	s.Router.Use(middleware.Heartbeat("/ping"))
@@ -25,10 +31,116 @@ func (s *Server) middleware() {
 			"https://api.sentinelvote.tech/",
 			"https://fablo.sentinelvote.tech/",
 		},
-		AllowedMethods:   []string{"HEAD", "GET", "POST", "PUT", "PATCH"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"HEAD", "GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-None-Match"},
+		ExposedHeaders:   []string{"Link", "ETag"},
 		AllowCredentials: false,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
 }
+
+// noCache sets the same response headers as middleware.NoCache, so that responses are not cached.
+// Unlike middleware.NoCache, it keeps the ETag headers of the request, so that a handler may let
+// its responses be revalidated by replacing these headers (see handleGetCurrentRing).
+func noCache(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Expires", time.Unix(0, 0).UTC().Format(http.TimeFormat))
+		w.Header().Set("Cache-Control", "no-cache, no-store, no-transform, must-revalidate, private, max-age=0")
+		w.Header().Set("Pragma", "no-cache")
+		w.Header().Set("X-Accel-Expires", "0")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// quietPaths are the suffixes of the paths that quietLogger does not log: casting, signing and reporting a vote.
+// Their timing and remote address would let anyone reading the logs pair voters with ballots.
+var quietPaths = []string{"/ballots", "/lrs/sign", "/has-voted"}
+
+// quietLogger is middleware.Logger, except for requests to quietPaths.
+func quietLogger(next http.Handler) http.Handler {
+	withLogger := middleware.Logger(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, path := range quietPaths {
+			if strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		withLogger.ServeHTTP(w, r)
+	})
+}
+
+// authenticate rejects requests without a valid session token,
+// and stores the token's claims in the request context.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || token == "" {
+			http.Error(w, "Missing session token", http.StatusUnauthorized)
+			return
+		}
+		claims, err := s.Sessions.Verify(token)
+		if err != nil {
+			http.Error(w, "Invalid session token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(session.NewContext(r.Context(), claims)))
+	})
+}
+
+// requireCentralAuthority only lets central authority sessions through.
+// It must be used after authenticate.
+func (s *Server) requireCentralAuthority(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if claims, ok := session.FromContext(r.Context()); !ok || !claims.IsCentralAuthority {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withElection stores the election named by the {electionID} URL parameter in the request context,
+// falling back to election.DefaultID for routes that are not prefixed with /elections/{electionID}.
+func (s *Server) withElection(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := election.DefaultID
+		if param := chi.URLParam(r, "electionID"); param != "" {
+			var err error
+			if id, err = strconv.ParseInt(param, 10, 64); err != nil {
+				http.Error(w, "Invalid election id", http.StatusBadRequest)
+				return
+			}
+		}
+
+		conn := s.Database.Get(r.Context())
+		e, found, err := election.Get(conn, id)
+		s.Database.Put(conn)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "Election not found", http.StatusNotFound)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(election.NewContext(r.Context(), e)))
+	})
+}
+
+// requireStatus only lets requests through if the election in the request context is in one of the given statuses.
+// It must be used after withElection.
+func (s *Server) requireStatus(statuses ...election.Status) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			e, _ := election.FromContext(r.Context())
+			for _, status := range statuses {
+				if e.Status == status {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			http.Error(w, "Not allowed while the election is in the '"+string(e.Status)+"' status", http.StatusConflict)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package cmd
+
+// Standard library on top, application and third-party packages below.
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/sentinelvote/backend/internal/election"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// Voters report that they have voted (see handleVoterUpdateHasVotedByEmail) right after casting their ballot,
+// so recording it at once would let anyone with the database and the bulletin pair voters with ballots by time.
+// Instead, voters are queued in the participation_queue table, which records neither when nor in which order
+// they were queued, and marked in batches, at random intervals, by one UPDATE per batch; the voter roll records
+// no timestamps either, so a batch is indistinguishable from any ordering of its voters. The queue is kept
+// in the database so that a restart does not lose it.
+// Turnout does not depend on these marks, as it is counted from the key images on the bulletin.
+
+const (
+	participationBatch    = 10               // Fewest voters marked at once while voting is open
+	participationMinDelay = time.Minute      // Shortest interval between batches
+	participationMaxDelay = 10 * time.Minute // Longest interval between batches
+)
+
+// queueVoted records that a voter has voted in an election, to be marked by the next batch.
+func queueVoted(conn *sqlite.Conn, electionID int64, voterUUID string) error {
+	return sqlitex.Execute(conn, `
+		INSERT INTO participation_queue (election_id, user_uuid) VALUES (?, ?)
+		ON CONFLICT DO NOTHING;`,
+		&sqlitex.ExecOptions{
+			Args: []any{electionID, voterUUID},
+		})
+}
+
+// unmarkVoted records that a voter has not voted in an election, whether they are marked or only queued.
+func unmarkVoted(conn *sqlite.Conn, electionID int64, voterUUID string) (err error) {
+	defer sqlitex.Save(conn)(&err)
+	err = sqlitex.Execute(conn, `DELETE FROM participation_queue WHERE election_id = ? AND user_uuid = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{electionID, voterUUID},
+		})
+	if err != nil {
+		return err
+	}
+	return sqlitex.Execute(conn, `UPDATE election_voters SET has_voted = FALSE WHERE election_id = ? AND user_uuid = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{electionID, voterUUID},
+		})
+}
+
+// participationRecorder marks queued voters on the voter roll, see markVoted, until ctx is cancelled.
+func (s *Server) participationRecorder(ctx context.Context) {
+	for {
+		delay := participationMinDelay + time.Duration(rand.Int63n(int64(participationMaxDelay-participationMinDelay)))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if err := s.markVoted(ctx); err != nil {
+			log.Println("Error marking voters who have voted: " + err.Error())
+		}
+	}
+}
+
+// markVoted marks the queued voters of each election on the voter roll, once there are at least
+// participationBatch of them. Once an election is no longer open for voting, its queued voters are
+// all marked, however few, as its ballots can no longer be told apart by time.
+func (s *Server) markVoted(ctx context.Context) (err error) {
+	conn := s.Database.Get(ctx)
+	if conn == nil {
+		return ctx.Err()
+	}
+	defer s.Database.Put(conn)
+	defer sqlitex.Save(conn)(&err)
+
+	var elections []int64
+	err = sqlitex.Execute(conn, `
+		SELECT q.election_id FROM participation_queue q JOIN elections e ON e.id = q.election_id
+		GROUP BY q.election_id
+		HAVING COUNT(*) >= ? OR e.status != ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{participationBatch, string(election.Voting)},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				elections = append(elections, stmt.ColumnInt64(0))
+				return nil
+			},
+		})
+	if err != nil {
+		return err
+	}
+	for _, electionID := range elections {
+		err = sqlitex.Execute(conn, `
+			UPDATE election_voters SET has_voted = TRUE
+			WHERE election_id = ?1 AND user_uuid IN (SELECT user_uuid FROM participation_queue WHERE election_id = ?1);`,
+			&sqlitex.ExecOptions{
+				Args: []any{electionID},
+			})
+		if err != nil {
+			return err
+		}
+		err = sqlitex.Execute(conn, `DELETE FROM participation_queue WHERE election_id = ?;`,
+			&sqlitex.ExecOptions{
+				Args: []any{electionID},
+			})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/sentinelvote/backend/internal/election"
 )
 
 func (s *Server) routes() {
@@ -17,36 +18,113 @@ func (s *Server) routes() {
 
 	s.Router.Route("/login", func(r chi.Router) {
 		r.Post("/", s.handleAuthLogin())
-		r.Post("/reset", s.handleAuthResetPassword())
-		r.Post("/update", s.handleAuthUpdatePassword())
+		r.With(s.authenticate, s.requireCentralAuthority).Post("/reset", s.handleAuthResetPassword())
+		r.With(s.authenticate).Post("/update", s.handleAuthUpdatePassword())
 	})
 
 	// Unprotected handlers (no authentication required).
 	s.Router.Get("/lrs/generate-keys", s.handleVoterGenerateKeys())
-	s.Router.Post("/lrs/sign", s.handleVoterSign())
-	s.Router.Get("/is-end-of-election", s.handleIsEndOfElection())
+	s.Router.Get("/elections", s.handleGetElections())
+	s.Router.Get("/health", s.handleHealth())
+
+	// Handlers of the default election, see election.DefaultID.
+	s.Router.With(s.withElection).Group(s.electionRoutes)
 
 	// Admin-only handlers (authentication required).
 	s.Router.Route("/admin", func(r chi.Router) {
-		r.Get("/users", s.handleAdminGetUsers())
-		r.Get("/folded-public-keys", s.handleAdminPutFoldedPublicKeys())
-		r.Get("/announce", s.handleAdminAnnounceResult())
+		r.Use(s.authenticate, s.requireCentralAuthority)
+		r.Get("/audit-log", s.handleAdminGetAuditLog())
+		r.Post("/elections", s.handleAdminCreateElection())
+		r.Get("/jobs", s.handleAdminGetJobs())
+		r.Get("/jobs/{jobID}", s.handleGetJob())
+		r.Delete("/jobs/{jobID}", s.handleAdminCancelJob())
+		r.With(s.withElection).Group(s.electionAdminRoutes)
 	})
 
-	// Voter-only handlers (authentication required).
+	// Voter handlers (authentication required).
+	// A central authority may also call these on behalf of a voter, see actingVoter.
 	s.Router.Route("/voter", func(r chi.Router) {
-		r.Patch("/has-voted", s.handleVoterUpdateHasVotedByEmail())
-		r.Patch("/keys", s.handleVoterUpdateKeysByEmail())
-		r.Post("/private-key", s.handleVoterGetPrivateKeyByEmail())
+		r.Use(s.authenticate, s.withElection)
+		s.electionVoterRoutes(r)
+	})
+
+	// Handlers of any election, the same as the ones above.
+	s.Router.Route("/elections/{electionID}", func(r chi.Router) {
+		r.Use(s.withElection)
+		s.electionRoutes(r)
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(s.authenticate, s.requireCentralAuthority)
+			s.electionAdminRoutes(r)
+		})
+		r.Route("/voter", func(r chi.Router) {
+			r.Use(s.authenticate)
+			s.electionVoterRoutes(r)
+		})
 	})
 
-	// Development-only handlers (no authentication required)
+	// Development-only handlers (authentication required).
+	// They expose and wipe the whole database, so they are as protected as /admin.
 	s.Router.Route("/dev", func(r chi.Router) {
+		r.Use(s.authenticate, s.requireCentralAuthority)
 		r.Get("/panic", s.handleDevPanic())
 		r.Get("/mem-system", s.handleDevMemSystem())
 		r.Get("/mem-app", s.handleDevMemApp())
 		r.Get("/db", s.handleDevDatabaseGetFullDatabase())
 		r.Get("/db/reset/{schema}/{users}", s.handleDevDatabaseReset())
 		r.Get("/blockchain/reset", s.handleDevBlockchainReset())
+		r.Get("/jobs/{jobID}", s.handleGetJob())
+	})
+}
+
+// electionRoutes registers the unprotected handlers scoped to an election.
+// The election is expected in the request context, see withElection.
+func (s *Server) electionRoutes(r chi.Router) {
+	r.With(s.requireStatus(election.Voting)).Post("/lrs/sign", s.handleVoterSign())
+	r.Post("/lrs/verify", s.handleVerifySignature())
+	r.With(s.requireStatus(election.Voting)).Post("/ballots", s.handleCastBallot())
+	r.Get("/receipts/{hash}", s.handleGetReceipt())
+	r.Route("/bulletin", func(r chi.Router) {
+		r.Use(s.requireStatus(election.Closed, election.Tallied, election.Published))
+		r.Get("/", s.handleGetBulletin())
+		r.Get("/archive", s.handleGetBulletinArchive())
 	})
+	r.Get("/is-end-of-election", s.handleIsEndOfElection())
+	r.Get("/status", s.handleGetElectionStatus())
+	r.Get("/ballot", s.handleGetBallot())
+	r.Get("/ring/current", s.handleGetCurrentRing())
+	r.With(s.requireStatus(election.Published)).Get("/results", s.handleGetResults())
+}
+
+// electionAdminRoutes registers the admin-only handlers scoped to an election.
+func (s *Server) electionAdminRoutes(r chi.Router) {
+	r.Get("/users", s.handleAdminGetUsers())
+	r.With(s.requireStatus(election.Draft, election.Registration)).Post("/voters", s.handleAdminEnrollVoters())
+	r.With(s.requireStatus(election.KeysFrozen)).Post("/folded-public-keys", s.handleAdminPutFoldedPublicKeys())
+	r.Route("/rings/fold", func(r chi.Router) {
+		r.With(s.requireStatus(election.KeysFrozen)).Post("/", s.handleAdminPutFoldedPublicKeys())
+		r.Get("/", s.handleAdminGetFoldJob())
+		r.Delete("/", s.handleAdminCancelFoldJob())
+	})
+	r.Post("/announce", s.handleAdminAnnounceResult())
+	r.Get("/announce", s.handleAdminAnnounceResult())
+	r.Post("/status", s.handleAdminTransitionElection())
+	r.With(s.requireStatus(election.KeysFrozen)).Post("/registration/reopen", s.handleAdminReopenRegistration())
+	r.Route("/candidates", func(r chi.Router) {
+		r.Use(s.requireStatus(election.Draft, election.Registration, election.KeysFrozen))
+		r.Post("/", s.handleAdminCreateCandidate())
+		r.Put("/{candidateID}", s.handleAdminUpdateCandidate())
+		r.Delete("/{candidateID}", s.handleAdminDeleteCandidate())
+	})
+	r.Get("/schedule", s.handleAdminGetSchedule())
+	r.Put("/schedule", s.handleAdminUpdateSchedule())
+	r.With(s.requireStatus(election.Closed)).Post("/tally", s.handleAdminTally())
+	r.With(s.requireStatus(election.Tallied, election.Published)).Get("/results", s.handleGetResults())
+}
+
+// electionVoterRoutes registers the voter handlers scoped to an election.
+func (s *Server) electionVoterRoutes(r chi.Router) {
+	r.With(s.requireStatus(election.Voting)).Patch("/has-voted", s.handleVoterUpdateHasVotedByEmail())
+	r.Patch("/keys", s.handleVoterUpdateKeysByEmail())
+	r.Post("/keys/challenge", s.handleVoterGetKeyChallenge())
+	r.Post("/private-key", s.handleVoterGetPrivateKeyByEmail())
 }
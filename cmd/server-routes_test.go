@@ -0,0 +1,58 @@
+package cmd
+
+// Standard library on top, application and third-party packages below.
+import (
+	"net/http"
+	"testing"
+
+	"github.com/sentinelvote/backend/internal/election"
+)
+
+func TestRequireStatus(t *testing.T) {
+	s := newTestServer(t, "simulation", 4)
+	admin := login(t, s, "admin@sentinelvote.tech")
+	voter := login(t, s, "user1@sentinelvote.tech")
+	var keys struct {
+		PublicKey  string `json:"publicKey"`
+		PrivateKey string `json:"privateKey"`
+	}
+	decode(t, do(t, s, http.MethodGet, "/lrs/generate-keys", "", nil), http.StatusOK, &keys)
+	candidate := map[string]any{"name": "Carol Ng"}
+
+	tests := []struct {
+		status election.Status
+		method string
+		path   string
+		token  string
+		body   any
+		want   int
+	}{
+		{election.Registration, http.MethodPatch, "/voter/keys", voter, keys, http.StatusOK},
+		{election.KeysFrozen, http.MethodPatch, "/voter/keys", voter, keys, http.StatusConflict},
+		{election.Voting, http.MethodPatch, "/voter/keys", voter, keys, http.StatusConflict},
+		{election.Registration, http.MethodPost, "/admin/candidates", admin, candidate, http.StatusOK},
+		{election.Voting, http.MethodPost, "/admin/candidates", admin, candidate, http.StatusConflict},
+		{election.Voting, http.MethodPost, "/admin/voters", admin, map[string]any{"emails": []string{"user1@sentinelvote.tech"}}, http.StatusConflict},
+		{election.Registration, http.MethodPost, "/admin/folded-public-keys", admin, nil, http.StatusConflict},
+		{election.Voting, http.MethodPost, "/admin/folded-public-keys", admin, nil, http.StatusConflict},
+		{election.Voting, http.MethodPost, "/admin/rings/fold", admin, nil, http.StatusConflict},
+		{election.Voting, http.MethodPost, "/admin/registration/reopen", admin, nil, http.StatusConflict},
+		{election.KeysFrozen, http.MethodPost, "/ballots", "", map[string]any{"message": "m", "signature": "s"}, http.StatusConflict},
+		{election.Closed, http.MethodPost, "/ballots", "", map[string]any{"message": "m", "signature": "s"}, http.StatusConflict},
+		{election.Closed, http.MethodPost, "/lrs/sign", "", map[string]any{"message": "m"}, http.StatusConflict},
+		{election.Closed, http.MethodPatch, "/voter/has-voted", voter, map[string]any{"hasVoted": true}, http.StatusConflict},
+		{election.Voting, http.MethodGet, "/bulletin", "", nil, http.StatusConflict},
+		{election.Closed, http.MethodGet, "/bulletin", "", nil, http.StatusOK},
+		{election.Voting, http.MethodPost, "/admin/tally", admin, map[string]any{}, http.StatusConflict},
+		{election.Closed, http.MethodGet, "/admin/results", admin, nil, http.StatusConflict},
+		{election.Tallied, http.MethodGet, "/results", "", nil, http.StatusConflict},
+	}
+	for _, test := range tests {
+		t.Run(string(test.status)+" "+test.method+" "+test.path, func(t *testing.T) {
+			setStatus(t, s, election.DefaultID, test.status)
+			if w := do(t, s, test.method, test.path, test.token, test.body); w.Code != test.want {
+				t.Errorf("got status %d, want %d: %s", w.Code, test.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package cmd
+
+// Standard library on top, third-party packages below.
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/sentinelvote/backend/internal/election"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// schedulerMaxSleep bounds how long the scheduler sleeps between passes,
+// so that schedules changed outside the API (e.g. by a resumed database) are picked up.
+const schedulerMaxSleep = time.Minute
+
+// scheduler opens and closes the voting windows of elections at their opens_at and closes_at timestamps.
+// Every pass reads the schedules from the database, so a server restarted with -resume
+// picks up where it left off, including transitions that fell due while it was down.
+//
+// Voting is only opened automatically from keys-frozen, once the ring has been published
+// by a central authority (see ringsPublished); publishing it wakes the scheduler.
+func (s *Server) scheduler(ctx context.Context) {
+	log.Println("Started the election scheduler")
+	warned := map[int64]string{}
+	for {
+		sleep := schedulerMaxSleep
+		next, err := s.runSchedule(ctx, time.Now(), warned)
+		if err != nil {
+			log.Println("Error running the election scheduler: " + err.Error())
+		} else if !next.IsZero() && time.Until(next) < sleep {
+			sleep = max(time.Until(next), 0)
+		}
+
+		timer := time.NewTimer(sleep)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.reschedule:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// wakeScheduler makes the scheduler re-read the schedules, without blocking.
+func (s *Server) wakeScheduler() {
+	select {
+	case s.reschedule <- struct{}{}:
+	default:
+	}
+}
+
+// runSchedule performs the transitions that are due at now, and returns when the next one is due.
+// The returned time is zero if nothing is scheduled.
+// An election that is due but cannot move is only logged once for as long as it stays so: warned holds
+// the last warning logged for each election, and is updated by every pass.
+func (s *Server) runSchedule(ctx context.Context, now time.Time, warned map[int64]string) (time.Time, error) {
+	conn := s.Database.Get(ctx)
+	if conn == nil {
+		return time.Time{}, ctx.Err()
+	}
+	defer s.Database.Put(conn)
+
+	var scheduled []election.Election
+	query := `
+		SELECT id, status, COALESCE(opens_at, 0), COALESCE(closes_at, 0) FROM elections
+		WHERE status IN (?, ?, ?) AND (opens_at IS NOT NULL OR closes_at IS NOT NULL);`
+	err := sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
+		Args: []any{string(election.Registration), string(election.KeysFrozen), string(election.Voting)},
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			scheduled = append(scheduled, election.Election{
+				ID:       stmt.ColumnInt64(0),
+				Status:   election.Status(stmt.ColumnText(1)),
+				OpensAt:  stmt.ColumnInt64(2),
+				ClosesAt: stmt.ColumnInt64(3),
+			})
+			return nil
+		},
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var next int64
+	later := func(at int64) {
+		if at != 0 && (next == 0 || at < next) {
+			next = at
+		}
+	}
+	transition := func(e election.Election, to election.Status) {
+		err := election.Transition(conn, e.ID, e.Status, to)
+		if errors.Is(err, election.ErrStatusChanged) {
+			return // A central authority got there first.
+		} else if err != nil {
+			log.Printf("Error moving election %d from '%s' to '%s': %v\n", e.ID, e.Status, to, err)
+			return
+		}
+		log.Printf("Scheduler moved election %d from '%s' to '%s'\n", e.ID, e.Status, to)
+	}
+	warnings := map[int64]string{}
+	warn := func(e election.Election, warning string) {
+		warnings[e.ID] = warning
+		if warned[e.ID] != warning {
+			log.Printf("Election %d %s\n", e.ID, warning)
+		}
+	}
+
+	unix := now.Unix()
+	for _, e := range scheduled {
+		switch e.Status {
+		case election.Voting:
+			if e.ClosesAt != 0 && e.ClosesAt <= unix {
+				transition(e, election.Closed)
+			} else {
+				later(e.ClosesAt)
+			}
+		case election.KeysFrozen:
+			if e.ClosesAt != 0 && e.ClosesAt <= unix {
+				warn(e, "was never opened before its voting window closed")
+			} else if e.OpensAt != 0 && e.OpensAt <= unix {
+				if published, err := scheduledRingsPublished(conn, e.ID); err != nil {
+					log.Printf("Error checking the ring of election %d: %v\n", e.ID, err)
+					continue
+				} else if !published {
+					warn(e, "is due to open, but its ring has not been published")
+					continue
+				}
+				transition(e, election.Voting)
+				later(e.ClosesAt)
+			} else {
+				later(e.OpensAt)
+			}
+		case election.Registration:
+			if e.OpensAt != 0 && e.OpensAt <= unix {
+				warn(e, "is due to open, but its public keys have not been frozen")
+			} else {
+				later(e.OpensAt)
+			}
+		}
+	}
+
+	clear(warned)
+	for id, warning := range warnings {
+		warned[id] = warning
+	}
+
+	if next == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(next, 0), nil
+}
+
+// scheduledRingsPublished reports whether the rings of an election due to open have been published.
+// Elections are read partially by runSchedule, so the election is read again for its ring mode and version.
+func scheduledRingsPublished(conn *sqlite.Conn, id int64) (bool, error) {
+	e, found, err := election.Get(conn, id)
+	if err != nil || !found {
+		return false, err
+	}
+	return ringsPublished(conn, e)
+}
@@ -1,7 +1,11 @@
 package cmd
 
 import (
+	"sync/atomic"
+
 	"github.com/go-chi/chi/v5"
+	"github.com/sentinelvote/backend/internal/foldpub"
+	"github.com/sentinelvote/backend/internal/session"
 	"zombiezen.com/go/sqlite/sqlitex"
 )
 
@@ -12,4 +16,11 @@ type Server struct {
 	TotalUsers int    // Number of users to create
 	PoolSize   int    // Number of connections to the database
 	Schema     string // `production` or `simulation` or `simulation_full`
+	Sessions   *session.Issuer
+	Ledger     foldpub.Ledger // Where folded public keys and ballots are published
+	Resume     bool           // Reuse the existing database instead of recreating it
+	reschedule chan struct{}  // Wakes the scheduler when a schedule changes
+	jobs       jobRunner      // Long-running operations, see submitJob
+	casting    hashLocks      // Ballots being submitted to the ledger, see handleCastBallot
+	simulation atomic.Bool    // Whether the database was created with a simulation schema, see isSimulation
 }
@@ -0,0 +1,143 @@
+package cmd
+
+// Standard library on top, application and third-party packages below.
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/goccy/go-json"
+	"github.com/sentinelvote/backend/internal/election"
+	"github.com/sentinelvote/backend/internal/foldpub"
+	"github.com/sentinelvote/backend/internal/session"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// TestMain runs the tests in a temporary directory, since the server keeps its databases
+// and debugging files in public/ under its working directory.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "sentinelvote-test")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "public"), 0755); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		log.Fatal(err)
+	}
+	log.SetOutput(io.Discard)
+
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// testServers numbers the databases of newTestServer, so that each test has its own.
+var testServers atomic.Int64
+
+// newTestServer returns a server with a new database of the given schema and number of users,
+// and an embedded ledger. Neither the scheduler nor the participation recorder runs.
+func newTestServer(t *testing.T, schema string, users int) *Server {
+	t.Helper()
+	n := testServers.Add(1)
+
+	sessions, err := session.NewIssuer("test secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger, err := foldpub.NewSQLite(filepath.Join("public", fmt.Sprintf("ledger-%d.db", n)), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{
+		Router:     chi.NewRouter(),
+		URI:        fmt.Sprintf("test-%d.db", n),
+		TotalUsers: users,
+		PoolSize:   4,
+		Schema:     schema,
+		Sessions:   sessions,
+		Ledger:     ledger,
+		reschedule: make(chan struct{}, 1),
+	}
+	s.middleware()
+	s.routes()
+	if err := s.database(s.URI); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.prepareJobs(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = s.Database.Close()
+		_ = ledger.Close()
+	})
+	return s
+}
+
+// do sends a request to the server as the holder of token, unless it is empty, with body in JSON unless it is nil.
+func do(t *testing.T, s *Server, method string, path string, token string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	r := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.Router.ServeHTTP(w, r)
+	return w
+}
+
+// decode reads a JSON response into v, failing the test unless it has the given status.
+func decode(t *testing.T, w *httptest.ResponseRecorder, status int, v any) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("got status %d, want %d: %s", w.Code, status, w.Body.String())
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("%v: %s", err, w.Body.String())
+	}
+}
+
+// login returns a session token of a user. Every user of a new database has the password "password".
+func login(t *testing.T, s *Server, email string) string {
+	t.Helper()
+	var res struct {
+		Token string `json:"token"`
+	}
+	decode(t, do(t, s, http.MethodPost, "/login", "", map[string]string{"email": email, "password": "password"}), http.StatusOK, &res)
+	return res.Token
+}
+
+// setStatus moves an election to a status directly, without the checks of its lifecycle.
+func setStatus(t *testing.T, s *Server, electionID int64, status election.Status) {
+	t.Helper()
+	conn := s.Database.Get(context.Background())
+	defer s.Database.Put(conn)
+	err := sqlitex.Execute(conn, `UPDATE elections SET status = ? WHERE id = ?;`, &sqlitex.ExecOptions{
+		Args: []any{string(status), electionID},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,96 @@
+package ballot
+
+// Standard library on top, third-party packages below.
+import (
+	"bytes"
+	"errors"
+
+	"github.com/goccy/go-json"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// Ballot is the message a voter signs.
+// Its canonical encoding is the compact JSON of the struct, with fields in declaration order:
+//
+//	{"election":1,"constituency":"BEDOK","candidate":3}
+type Ballot struct {
+	Election     int64  `json:"election"`
+	Constituency string `json:"constituency"`
+	Candidate    int64  `json:"candidate"`
+}
+
+var ErrNotCanonical = errors.New("ballot: message is not a canonically encoded ballot")
+
+// Encode returns the canonical encoding of b.
+func (b Ballot) Encode() []byte {
+	encoded, _ := json.Marshal(b) // Cannot fail, all fields are plain values.
+	return encoded
+}
+
+// Decode parses a canonically encoded ballot.
+// Messages which decode but differ from their canonical encoding are rejected, so that
+// a ballot has exactly one encoding, and therefore exactly one valid signed message.
+func Decode(message []byte) (Ballot, error) {
+	var b Ballot
+	decoder := json.NewDecoder(bytes.NewReader(message))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&b); err != nil {
+		return Ballot{}, ErrNotCanonical
+	}
+	if !bytes.Equal(message, b.Encode()) {
+		return Ballot{}, ErrNotCanonical
+	}
+	return b, nil
+}
+
+// +----------------------------------------------------------------------------------------------+
+// |                                          Candidates                                          |
+// +----------------------------------------------------------------------------------------------+
+
+// Candidate is a row of the candidates table.
+// A candidate with an empty Constituency stands in every constituency.
+type Candidate struct {
+	ID           int64  `json:"id"`
+	Constituency string `json:"constituency"`
+	Name         string `json:"name"`
+	Party        string `json:"party"`
+	Position     int64  `json:"position"`
+}
+
+// ListCandidates returns the candidates of an election in ballot order.
+func ListCandidates(conn *sqlite.Conn, electionID int64) ([]Candidate, error) {
+	candidates := []Candidate{}
+	query := `
+		SELECT id, constituency, name, party, position FROM candidates
+		WHERE election_id = ?
+		ORDER BY constituency, position, id;`
+	err := sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
+		Args: []any{electionID},
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			candidates = append(candidates, Candidate{
+				ID:           stmt.ColumnInt64(0),
+				Constituency: stmt.ColumnText(1),
+				Name:         stmt.ColumnText(2),
+				Party:        stmt.ColumnText(3),
+				Position:     stmt.ColumnInt64(4),
+			})
+			return nil
+		},
+	})
+	return candidates, err
+}
+
+// Eligible returns a function reporting whether a ballot is a valid choice in an election,
+// i.e. whether its candidate stands in its constituency.
+func Eligible(electionID int64, candidates []Candidate) func(Ballot) bool {
+	standsIn := make(map[int64]string, len(candidates))
+	for _, c := range candidates {
+		standsIn[c.ID] = c.Constituency
+	}
+	return func(b Ballot) bool {
+		constituency, ok := standsIn[b.Candidate]
+		return ok && b.Election == electionID && b.Constituency != "" &&
+			(constituency == "" || constituency == b.Constituency)
+	}
+}
@@ -0,0 +1,80 @@
+package ballot
+
+// Standard library on top, third-party packages below.
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		err     error
+	}{
+		{"canonical", `{"election":1,"constituency":"BEDOK","candidate":3}`, nil},
+		{"spaces", `{"election":1, "constituency":"BEDOK", "candidate":3}`, ErrNotCanonical},
+		{"indented", "{\n\t\"election\":1,\n\t\"constituency\":\"BEDOK\",\n\t\"candidate\":3\n}", ErrNotCanonical},
+		{"trailing newline", "{\"election\":1,\"constituency\":\"BEDOK\",\"candidate\":3}\n", ErrNotCanonical},
+		{"second ballot", `{"election":1,"constituency":"BEDOK","candidate":3}{"election":1,"constituency":"BEDOK","candidate":4}`, ErrNotCanonical},
+		{"field order", `{"candidate":3,"election":1,"constituency":"BEDOK"}`, ErrNotCanonical},
+		{"duplicate field", `{"election":1,"constituency":"BEDOK","candidate":3,"candidate":4}`, ErrNotCanonical},
+		{"unknown field", `{"election":1,"constituency":"BEDOK","candidate":3,"nonce":7}`, ErrNotCanonical},
+		{"missing field", `{"election":1,"candidate":3}`, ErrNotCanonical},
+		{"escaped string", `{"election":1,"constituency":"B\u0045DOK","candidate":3}`, ErrNotCanonical},
+		{"float", `{"election":1,"constituency":"BEDOK","candidate":3.0}`, ErrNotCanonical},
+		{"quoted number", `{"election":"1","constituency":"BEDOK","candidate":3}`, ErrNotCanonical},
+		{"null", `null`, ErrNotCanonical},
+		{"empty", ``, ErrNotCanonical},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := Decode([]byte(test.message))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got %v, want %v", err, test.err)
+			}
+			if err == nil && b != (Ballot{Election: 1, Constituency: "BEDOK", Candidate: 3}) {
+				t.Errorf("got %+v", b)
+			}
+		})
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	for _, b := range []Ballot{
+		{Election: 1, Constituency: "BEDOK", Candidate: 3},
+		{Election: 2, Constituency: "NORTH-EASTERN ISLANDS", Candidate: 1 << 40},
+		{Election: 3, Constituency: `"A&B"`, Candidate: -1},
+	} {
+		decoded, err := Decode(b.Encode())
+		if err != nil || decoded != b {
+			t.Errorf("got %+v, %v, want %+v", decoded, err, b)
+		}
+	}
+}
+
+func TestEligible(t *testing.T) {
+	eligible := Eligible(1, []Candidate{
+		{ID: 1, Name: "Alice Tan"},
+		{ID: 2, Name: "Bob Lim", Constituency: "BEDOK"},
+	})
+	tests := []struct {
+		name   string
+		ballot Ballot
+		want   bool
+	}{
+		{"candidate of every constituency", Ballot{Election: 1, Constituency: "YISHUN", Candidate: 1}, true},
+		{"candidate of the constituency", Ballot{Election: 1, Constituency: "BEDOK", Candidate: 2}, true},
+		{"candidate of another constituency", Ballot{Election: 1, Constituency: "YISHUN", Candidate: 2}, false},
+		{"unknown candidate", Ballot{Election: 1, Constituency: "BEDOK", Candidate: 3}, false},
+		{"other election", Ballot{Election: 2, Constituency: "BEDOK", Candidate: 1}, false},
+		{"no constituency", Ballot{Election: 1, Candidate: 1}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := eligible(test.ballot); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package bulletin
+
+// Standard library on top, third-party packages below.
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// Entry is a signed ballot accepted by the server and submitted to the ledger, as recorded in the bulletin table.
+// It records no time, so that ballots cannot be matched with voters by when they were cast: ID only orders entries.
+type Entry struct {
+	ID           int64  `json:"id"`
+	ElectionID   int64  `json:"electionId"`
+	Hash         string `json:"hash"` // See Hash
+	Constituency string `json:"constituency,omitempty"`
+	Message      string `json:"message"`
+	Signature    string `json:"signature"`
+	KeyImage     string `json:"keyImage"`
+	Ring         string `json:"ring"` // Hash of the folded public keys the signature was verified against
+	TxID         string `json:"txId,omitempty"`
+}
+
+// Receipt is what a voter keeps of their ballot. It names neither the voter nor their choice.
+type Receipt struct {
+	Hash       string `json:"hash"`
+	ElectionID int64  `json:"electionId"`
+	KeyImage   string `json:"keyImage"`
+	Ring       string `json:"ring"`
+	TxID       string `json:"txId,omitempty"`
+}
+
+// Receipt returns the receipt of an entry.
+func (e Entry) Receipt() Receipt {
+	return Receipt{
+		Hash:       e.Hash,
+		ElectionID: e.ElectionID,
+		KeyImage:   e.KeyImage,
+		Ring:       e.Ring,
+		TxID:       e.TxID,
+	}
+}
+
+// Hash returns the hex SHA-256 that identifies a signed ballot: of its message, a newline, then its signature.
+// A ballot message never contains a newline, see ballot.Encode.
+func Hash(message []byte, signature []byte) string {
+	digest := sha256.New()
+	digest.Write(message)
+	digest.Write([]byte{'\n'})
+	digest.Write(signature)
+	return hex.EncodeToString(digest.Sum(nil))
+}
+
+// Record adds an entry to the bulletin, and returns it as recorded.
+// An entry with the same hash is only recorded once: the entry already recorded is returned instead.
+func Record(conn *sqlite.Conn, entry Entry) (Entry, error) {
+	err := sqlitex.Execute(conn, `
+		INSERT INTO bulletin (election_id, hash, constituency, message, signature, key_image, ring, tx_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (hash) DO NOTHING;`,
+		&sqlitex.ExecOptions{
+			Args: []any{entry.ElectionID, entry.Hash, entry.Constituency, entry.Message, entry.Signature,
+				entry.KeyImage, entry.Ring, entry.TxID},
+		})
+	if err != nil {
+		return Entry{}, err
+	}
+	recorded, _, err := Get(conn, entry.ElectionID, entry.Hash)
+	return recorded, err
+}
+
+// Turnout returns how many voters have cast a ballot in an election: the number of distinct key images
+// on its bulletin, as a voter who casts several ballots signs them all with the same key image.
+func Turnout(conn *sqlite.Conn, electionID int64) (int, error) {
+	var turnout int
+	err := sqlitex.Execute(conn, `SELECT COUNT(DISTINCT key_image) FROM bulletin WHERE election_id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{electionID},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				turnout = stmt.ColumnInt(0)
+				return nil
+			},
+		})
+	return turnout, err
+}
+
+const columns = `id, election_id, hash, constituency, message, signature, key_image, ring, tx_id`
+
+// Get returns the entry of an election with the given hash, and false if there is none.
+func Get(conn *sqlite.Conn, electionID int64, hash string) (Entry, bool, error) {
+	var entry Entry
+	var found bool
+	err := sqlitex.Execute(conn, `SELECT `+columns+` FROM bulletin WHERE election_id = ? AND hash = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{electionID, hash},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				entry, found = scan(stmt), true
+				return nil
+			},
+		})
+	return entry, found, err
+}
+
+// List returns up to limit entries of an election, in the order they were recorded, starting after the entry
+// with id after (0 to start from the first one).
+func List(conn *sqlite.Conn, electionID int64, after int64, limit int) ([]Entry, error) {
+	entries := []Entry{}
+	err := sqlitex.Execute(conn, `
+		SELECT `+columns+` FROM bulletin
+		WHERE election_id = ? AND id > ?
+		ORDER BY id
+		LIMIT ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{electionID, after, limit},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				entries = append(entries, scan(stmt))
+				return nil
+			},
+		})
+	return entries, err
+}
+
+// scan reads an entry selected with columns.
+func scan(stmt *sqlite.Stmt) Entry {
+	return Entry{
+		ID:           stmt.ColumnInt64(0),
+		ElectionID:   stmt.ColumnInt64(1),
+		Hash:         stmt.ColumnText(2),
+		Constituency: stmt.ColumnText(3),
+		Message:      stmt.ColumnText(4),
+		Signature:    stmt.ColumnText(5),
+		KeyImage:     stmt.ColumnText(6),
+		Ring:         stmt.ColumnText(7),
+		TxID:         stmt.ColumnText(8),
+	}
+}
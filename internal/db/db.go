@@ -7,7 +7,7 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/sentinelvote/backend/internal/foldpub"
+	"github.com/sentinelvote/backend/internal/election"
 	"zombiezen.com/go/sqlite"
 	"zombiezen.com/go/sqlite/sqlitex"
 )
@@ -21,10 +21,31 @@ const (
 	SIMULATION
 
 	// Public keys and private keys are initialized in the database,
-	// and folded public keys are inserted into the blockchain.
+	// and folded public keys are inserted into the ledger by the caller of CreateSchema, which then opens voting.
 	SIMULATION_FULL
 )
 
+// schemaNames are the names of the purposes, as given to -schema and recorded in the database_schema table.
+var schemaNames = map[int]string{
+	PRODUCTION:      "production",
+	SIMULATION:      "simulation",
+	SIMULATION_FULL: "simulation-full",
+}
+
+// CreatedSchema returns the name of the schema the database was created with, see CreateSchema,
+// and false if the database does not record it.
+func CreatedSchema(conn *sqlite.Conn) (string, bool, error) {
+	var name string
+	var found bool
+	err := sqlitex.Execute(conn, `SELECT name FROM database_schema;`, &sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			name, found = stmt.ColumnText(0), true
+			return nil
+		},
+	})
+	return name, found, err
+}
+
 func CreateSchema(conn *sqlite.Conn, purpose int, totalUsers int) error {
 
 	log.Println("Creating schema...")
@@ -32,10 +53,13 @@ func CreateSchema(conn *sqlite.Conn, purpose int, totalUsers int) error {
 	// Perform a string replacement to insert our chosen number of users.
 	// We also minus two, because we inserted 2 users from InsertDefault.
 	var insertMany string
+	var insertElection string
 	if purpose == PRODUCTION {
 		insertMany = strings.ReplaceAll(InsertProduction, "?1", strconv.Itoa(totalUsers-2))
+		insertElection = strings.ReplaceAll(InsertElection, "?2", string(election.Registration))
 	} else {
 		insertMany = strings.ReplaceAll(InsertSimulation, "?1", strconv.Itoa(totalUsers-2))
+		insertElection = strings.ReplaceAll(InsertElection, "?2", string(election.KeysFrozen))
 	}
 
 	// The SQL transaction string to be executed.
@@ -48,6 +72,8 @@ func CreateSchema(conn *sqlite.Conn, purpose int, totalUsers int) error {
 		LastNames,
 		InsertDefault,
 		insertMany,
+		insertElection,
+		"INSERT INTO database_schema (name) VALUES ('" + schemaNames[purpose] + "');",
 	}, sep)
 
 	// Write the query string to disk (for debugging purposes).
@@ -66,19 +92,6 @@ func CreateSchema(conn *sqlite.Conn, purpose int, totalUsers int) error {
 		return err
 	}
 
-	// A full simulation will also store the folded public keys in the blockchain.
-	if purpose == SIMULATION_FULL {
-		if response, err := foldpub.PutFoldedPublicKeys(conn); err != nil {
-			log.Println("Unable to insert folded public keys into the blockchain.")
-			log.Println("Error message: " + err.Error())
-		} else if response != "OK" {
-			log.Println("Unable to insert folded public keys into the blockchain.")
-			log.Println("Error message: " + response)
-		} else {
-			log.Println("Successfully inserted folded public keys into the blockchain.")
-		}
-	}
-
 	return nil
 }
 
@@ -25,3 +25,8 @@ var InsertProduction string
 
 //go:embed insert_simulation.sql
 var InsertSimulation string
+
+// InsertElection creates the default election, with every voter on its voter roll.
+//
+//go:embed insert_election.sql
+var InsertElection string
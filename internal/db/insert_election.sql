@@ -0,0 +1,19 @@
+/*
+Creates the default election (election.DefaultID),
+and enrolls every voter onto its voter roll.
+
+?2 is modified by db.go to the initial status of the election,
+which is 'registration' for production and 'keys-frozen' for simulations,
+as simulated voters already have their keys. A full simulation then publishes
+its ring and opens voting.
+*/
+
+-- noinspection SqlResolveForFile
+INSERT INTO elections (id, title, status) VALUES (1, 'General Election', '?2');
+
+INSERT INTO election_voters (election_id, user_uuid)
+SELECT 1, uuid FROM users WHERE is_central_authority = FALSE;
+
+INSERT INTO candidates (election_id, name, party, position) VALUES
+(1, 'Alice Tan', 'Independent', 1),
+(1, 'Bob Lim', 'Independent', 2);
@@ -1,20 +1,39 @@
+DROP TABLE IF EXISTS bulletin;
+DROP TABLE IF EXISTS key_challenges;
+DROP TABLE IF EXISTS ring_snapshots;
+DROP TABLE IF EXISTS tally_ballots;
+DROP TABLE IF EXISTS tally_results;
+DROP TABLE IF EXISTS tallies;
+DROP TABLE IF EXISTS candidates;
+DROP TABLE IF EXISTS participation_queue;
+DROP TABLE IF EXISTS election_voters;
+DROP TABLE IF EXISTS elections;
 DROP TABLE IF EXISTS users;
 DROP TABLE IF EXISTS is_end_of_election;
+DROP TABLE IF EXISTS audit_log;
 DROP TABLE IF EXISTS constituencies;
 DROP TABLE IF EXISTS first_names;
 DROP TABLE IF EXISTS last_names;
+DROP TABLE IF EXISTS database_schema;
 
 /*
 PRIMARY KEYS must also be declared NOT NULL:
 https://www.sqlite.org/lang_createtable.html#primkeyconst
 */
 
+/*
+The schema the database was created with, see db.CreateSchema, so that a resumed or reset database
+is not mistaken for a simulation, or the other way around.
+*/
+CREATE TABLE database_schema (
+name                 TEXT                NOT NULL
+);
+
 CREATE TABLE users (
 uuid                 TEXT    PRIMARY KEY NOT NULL,
 email                TEXT    UNIQUE      NOT NULL,
 password             TEXT                NOT NULL DEFAULT 'password',
 public_key           TEXT                NOT NULL DEFAULT '',
-has_voted            BOOLEAN             NOT NULL DEFAULT FALSE,
 has_default_password BOOLEAN             NOT NULL DEFAULT TRUE,
 constituency         TEXT                NOT NULL DEFAULT 'N/A',
 first_name           TEXT                NOT NULL DEFAULT 'N/A',
@@ -23,6 +42,153 @@ is_central_authority BOOLEAN             NOT NULL DEFAULT FALSE,
 private_key          TEXT                NOT NULL DEFAULT ''
 );
 
-CREATE TABLE is_end_of_election (
-is_end_of_election   INT2    PRIMARY KEY NOT NULL CHECK ( is_end_of_election = 1 )
+/*
+A ring with the same public key twice would let either voter sign for both, see voterkey.InUse,
+which reports it before this index rejects it.
+*/
+CREATE UNIQUE INDEX users_public_key ON users (public_key) WHERE public_key != '';
+
+/*
+opens_at and closes_at are unix seconds, NULL when unscheduled.
+status is a stage of the lifecycle in internal/election/lifecycle.go.
+*/
+CREATE TABLE elections (
+id                   INTEGER PRIMARY KEY NOT NULL,
+title                TEXT                NOT NULL,
+opens_at             INTEGER                      DEFAULT NULL,
+closes_at            INTEGER                      DEFAULT NULL,
+status               TEXT                NOT NULL DEFAULT 'draft' CHECK ( status IN (
+                                             'draft', 'registration', 'keys-frozen', 'voting', 'closed', 'tallied', 'published'
+                                         ) ),
+ring_mode            TEXT                NOT NULL DEFAULT 'election' CHECK ( ring_mode IN ('election', 'constituency') ),
+ring_version         INTEGER             NOT NULL DEFAULT 1,
+status_updated_at    INTEGER             NOT NULL DEFAULT (unixepoch()),
+created_at           INTEGER             NOT NULL DEFAULT (unixepoch())
+);
+
+/*
+The voter roll of each election.
+*/
+CREATE TABLE election_voters (
+election_id          INTEGER             NOT NULL REFERENCES elections (id) ON DELETE CASCADE,
+user_uuid            TEXT                NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+has_voted            BOOLEAN             NOT NULL DEFAULT FALSE,
+PRIMARY KEY (election_id, user_uuid)
+);
+
+/*
+Voters who have reported voting but are not marked on the voter roll yet, see cmd/server-participation.go.
+It has neither a rowid nor a timestamp, so it does not record the order voters were queued in.
+*/
+CREATE TABLE participation_queue (
+election_id          INTEGER             NOT NULL REFERENCES elections (id) ON DELETE CASCADE,
+user_uuid            TEXT                NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+PRIMARY KEY (election_id, user_uuid)
+) WITHOUT ROWID;
+
+/*
+Records actions a central authority performed on behalf of a voter.
+target_uuid is NULL for an action that must not be linked to its voter, such as marking them as having voted.
+*/
+CREATE TABLE audit_log (
+id                   INTEGER PRIMARY KEY NOT NULL,
+actor_uuid           TEXT                NOT NULL,
+action               TEXT                NOT NULL,
+target_uuid          TEXT,
+created_at           INTEGER             NOT NULL DEFAULT (unixepoch())
+);
+
+/*
+The choices on the ballot of an election, see internal/ballot.
+An empty constituency means the candidate stands in every constituency.
+*/
+CREATE TABLE candidates (
+id                   INTEGER PRIMARY KEY NOT NULL,
+election_id          INTEGER             NOT NULL REFERENCES elections (id) ON DELETE CASCADE,
+constituency         TEXT                NOT NULL DEFAULT '',
+name                 TEXT                NOT NULL,
+party                TEXT                NOT NULL DEFAULT '',
+position             INTEGER             NOT NULL DEFAULT 0,
+UNIQUE (election_id, constituency, name)
+);
+
+/*
+The outcome of counting the ballots of an election, see internal/tally.
+constituency_rings is whether ballots were verified against the ring of the constituency they name,
+without which the votes per constituency in tally_results rest on what each ballot claims.
+*/
+CREATE TABLE tallies (
+election_id          INTEGER PRIMARY KEY NOT NULL REFERENCES elections (id) ON DELETE CASCADE,
+policy               TEXT                NOT NULL,
+total                INTEGER             NOT NULL,
+counted              INTEGER             NOT NULL,
+invalid              INTEGER             NOT NULL,
+duplicates           INTEGER             NOT NULL,
+constituency_rings   BOOLEAN             NOT NULL,
+created_at           INTEGER             NOT NULL DEFAULT (unixepoch())
+);
+
+CREATE TABLE tally_results (
+election_id          INTEGER             NOT NULL REFERENCES tallies (election_id) ON DELETE CASCADE,
+constituency         TEXT                NOT NULL,
+candidate_id         INTEGER             NOT NULL,
+votes                INTEGER             NOT NULL,
+PRIMARY KEY (election_id, constituency, candidate_id)
+);
+
+/*
+The outcome of each ballot on the ledger, by its hash (see internal/bulletin), so that voters can check their receipt.
+A ballot recorded twice by the ledger has the outcome of the copy that counted.
+*/
+CREATE TABLE tally_ballots (
+election_id          INTEGER             NOT NULL REFERENCES tallies (election_id) ON DELETE CASCADE,
+hash                 TEXT                NOT NULL,
+reference            TEXT                NOT NULL,
+key_image            TEXT                NOT NULL,
+outcome              TEXT                NOT NULL,
+PRIMARY KEY (election_id, hash)
+);
+
+/*
+Every ring (folded public keys) published to the ledger, see internal/foldpub.
+The latest snapshot of an election is its current ring, and hash is the hex SHA-256 of folded_public_keys.
+With a ring_mode of 'constituency', each constituency has its own current ring, otherwise constituency is empty.
+Reopening registration bumps the election's ring_version, and snapshots of an older ring_version are no longer current.
+*/
+CREATE TABLE ring_snapshots (
+id                   INTEGER PRIMARY KEY NOT NULL,
+election_id          INTEGER             NOT NULL REFERENCES elections (id) ON DELETE CASCADE,
+constituency         TEXT                NOT NULL DEFAULT '',
+hash                 TEXT                NOT NULL,
+members              INTEGER             NOT NULL,
+folded_public_keys   TEXT                NOT NULL,
+tx_id                TEXT                NOT NULL DEFAULT '',
+ring_version         INTEGER             NOT NULL DEFAULT 1,
+created_at           INTEGER             NOT NULL DEFAULT (unixepoch())
+);
+
+/*
+Single-use nonces issued to voters, who sign them to prove possession of their private key, see internal/voterkey.
+*/
+CREATE TABLE key_challenges (
+nonce                TEXT    PRIMARY KEY NOT NULL,
+user_uuid            TEXT                NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+expires_at           INTEGER             NOT NULL
+);
+
+/*
+Signed ballots accepted by POST /ballots and submitted to the ledger, see internal/bulletin.
+hash identifies a ballot on its voter's receipt, and ring is the hash of the ring it was verified against.
+It has no timestamp: entries are ordered by id, see internal/bulletin.
+*/
+CREATE TABLE bulletin (
+id                   INTEGER PRIMARY KEY NOT NULL,
+election_id          INTEGER             NOT NULL REFERENCES elections (id) ON DELETE CASCADE,
+hash                 TEXT                NOT NULL UNIQUE,
+constituency         TEXT                NOT NULL DEFAULT '',
+message              TEXT                NOT NULL,
+signature            TEXT                NOT NULL,
+key_image            TEXT                NOT NULL,
+ring                 TEXT                NOT NULL,
+tx_id                TEXT                NOT NULL DEFAULT ''
 );
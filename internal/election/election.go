@@ -0,0 +1,87 @@
+package election
+
+// Standard library on top, third-party packages below.
+import (
+	"context"
+	"strconv"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// DefaultID is the election created alongside the schema.
+// Routes that are not prefixed with /elections/{electionID} act on it.
+const DefaultID int64 = 1
+
+// Election is a row of the elections table.
+// OpensAt and ClosesAt are unix seconds, or zero when unscheduled.
+// RingVersion counts how many times the rings have been frozen, see ReopenRegistration.
+type Election struct {
+	ID          int64
+	Title       string
+	OpensAt     int64
+	ClosesAt    int64
+	Status      Status
+	RingMode    RingMode
+	RingVersion int
+}
+
+// RingMode decides how the public keys on the voter roll are folded into rings.
+type RingMode string
+
+const (
+	RingPerElection     RingMode = "election"     // One ring of every voter.
+	RingPerConstituency RingMode = "constituency" // One ring per constituency, of the voters living there.
+)
+
+// Valid reports whether m is a known ring mode.
+func (m RingMode) Valid() bool {
+	return m == RingPerElection || m == RingPerConstituency
+}
+
+// Get returns the election with the given id, and false if it does not exist.
+func Get(conn *sqlite.Conn, id int64) (Election, bool, error) {
+	var e Election
+	var found bool
+	query := `SELECT id, title, COALESCE(opens_at, 0), COALESCE(closes_at, 0), status, ring_mode, ring_version FROM elections WHERE id = ?;`
+	err := sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
+		Args: []any{id},
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			e.ID = stmt.ColumnInt64(0)
+			e.Title = stmt.ColumnText(1)
+			e.OpensAt = stmt.ColumnInt64(2)
+			e.ClosesAt = stmt.ColumnInt64(3)
+			e.Status = Status(stmt.ColumnText(4))
+			e.RingMode = RingMode(stmt.ColumnText(5))
+			e.RingVersion = stmt.ColumnInt(6)
+			found = true
+			return nil
+		},
+	})
+	return e, found, err
+}
+
+// CaseIdentifier is passed to lirisi when signing and verifying ballots, so that
+// a voter's key images are unlinkable across elections sharing the same ring.
+// It is the same for every constituency ring, since a voter is only a member of one.
+// Every signature of an election is created and verified with it, by the server and by the recount.
+func CaseIdentifier(id int64) []byte {
+	return []byte("sentinelvote/election/" + strconv.FormatInt(id, 10))
+}
+
+// +----------------------------------------------------------------------------------------------+
+// |                                           Context                                            |
+// +----------------------------------------------------------------------------------------------+
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the election.
+func NewContext(ctx context.Context, e Election) context.Context {
+	return context.WithValue(ctx, contextKey{}, e)
+}
+
+// FromContext returns the election stored by NewContext, if any.
+func FromContext(ctx context.Context) (Election, bool) {
+	e, ok := ctx.Value(contextKey{}).(Election)
+	return e, ok
+}
@@ -0,0 +1,135 @@
+package election
+
+// Standard library on top, third-party packages below.
+import (
+	"errors"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// Status is a stage in the lifecycle of an election.
+// An election moves through the stages in the order they are declared,
+// except that registration may be reopened before voting, see ReopenRegistration.
+type Status string
+
+const (
+	Draft        Status = "draft"        // Being set up, voter roll may change.
+	Registration Status = "registration" // Voters register their public keys.
+	KeysFrozen   Status = "keys-frozen"  // Public keys may no longer change, the ring is published.
+	Voting       Status = "voting"       // Voters sign and cast ballots.
+	Closed       Status = "closed"       // Ballots are no longer accepted.
+	Tallied      Status = "tallied"      // Ballots have been counted.
+	Published    Status = "published"    // Results are public.
+)
+
+var (
+	ErrUnknownStatus     = errors.New("election: unknown status")
+	ErrInvalidTransition = errors.New("election: invalid status transition")
+	ErrStatusChanged     = errors.New("election: status was changed concurrently")
+)
+
+// transitions maps each status to the statuses it may move to.
+var transitions = map[Status][]Status{
+	Draft:        {Registration},
+	Registration: {KeysFrozen},
+	KeysFrozen:   {Voting},
+	Voting:       {Closed},
+	Closed:       {Tallied},
+	Tallied:      {Published},
+	Published:    {},
+}
+
+// Valid reports whether s is a known status.
+func (s Status) Valid() bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+// Next returns the statuses that s may move to.
+func (s Status) Next() []Status {
+	return transitions[s]
+}
+
+// CanTransitionTo reports whether s may move to next.
+func (s Status) CanTransitionTo(next Status) bool {
+	for _, candidate := range transitions[s] {
+		if candidate == next {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptsKeys reports whether voters may still register or change their public keys.
+func (s Status) AcceptsKeys() bool {
+	return s == Draft || s == Registration
+}
+
+// Ended reports whether voting is over.
+func (s Status) Ended() bool {
+	return s == Closed || s == Tallied || s == Published
+}
+
+// Transition moves an election from one status to the next.
+// It fails with ErrStatusChanged if the election is no longer in the from status.
+func Transition(conn *sqlite.Conn, id int64, from Status, to Status) error {
+	if !from.Valid() || !to.Valid() {
+		return ErrUnknownStatus
+	}
+	if !from.CanTransitionTo(to) {
+		return ErrInvalidTransition
+	}
+	query := `UPDATE elections SET status = ?, status_updated_at = unixepoch() WHERE id = ? AND status = ?;`
+	err := sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
+		Args: []any{string(to), id, string(from)},
+	})
+	if err != nil {
+		return err
+	}
+	if conn.Changes() == 0 {
+		return ErrStatusChanged
+	}
+	return nil
+}
+
+// ReopenRegistration moves an election from keys-frozen back to registration, so that voters may change their
+// public keys again, and bumps its ring version: the rings published so far no longer count, and must be published
+// again once keys are frozen. It is not a regular transition, as it is refused once voting has started.
+// It fails with ErrStatusChanged if the election is no longer in keys-frozen.
+func ReopenRegistration(conn *sqlite.Conn, id int64) error {
+	query := `
+		UPDATE elections SET status = ?, ring_version = ring_version + 1, status_updated_at = unixepoch()
+		WHERE id = ? AND status = ?;`
+	err := sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
+		Args: []any{string(Registration), id, string(KeysFrozen)},
+	})
+	if err != nil {
+		return err
+	}
+	if conn.Changes() == 0 {
+		return ErrStatusChanged
+	}
+	return nil
+}
+
+// KeysFrozenFor reports whether a voter is enrolled in an election whose ring is in use,
+// in which case their public key must not change.
+// The ring is in use from KeysFrozen until the ballots are tallied.
+func KeysFrozenFor(conn *sqlite.Conn, userUUID string) (bool, error) {
+	var frozen bool
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM election_voters v
+			JOIN elections e ON e.id = v.election_id
+			WHERE v.user_uuid = ? AND e.status IN (?, ?, ?)
+		);`
+	err := sqlitex.Execute(conn, query, &sqlitex.ExecOptions{
+		Args: []any{userUUID, string(KeysFrozen), string(Voting), string(Closed)},
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			frozen = stmt.ColumnBool(0)
+			return nil
+		},
+	})
+	return frozen, err
+}
@@ -0,0 +1,116 @@
+package foldpub
+
+// Standard library on top, third-party packages below.
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// breakerThreshold is the number of consecutive failed calls that opens the circuit.
+	breakerThreshold = 5
+
+	// breakerCooldown is how long the circuit stays open before a single call may try again.
+	breakerCooldown = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned without calling the ledger while it is considered down.
+var ErrCircuitOpen = errors.New("foldpub: the ledger is unavailable, try again later")
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"    // Calls go through.
+	CircuitOpen     CircuitState = "open"      // Calls fail fast with ErrCircuitOpen.
+	CircuitHalfOpen CircuitState = "half-open" // A single call goes through to probe the ledger.
+)
+
+// breaker stops calling the ledger after repeated failures, so that handlers fail fast
+// instead of each waiting for a timeout, and lets a single call through once it has cooled down.
+type breaker struct {
+	now func() time.Time
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newBreaker() *breaker {
+	return &breaker{now: time.Now, state: CircuitClosed}
+}
+
+// Allow reports whether a call may go through. Every allowed call must be followed by Done or Release.
+func (b *breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if b.now().Sub(b.openedAt) < breakerCooldown {
+			return ErrCircuitOpen
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return nil
+	case CircuitHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Done records the outcome of an allowed call.
+func (b *breaker) Done(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		b.state = CircuitClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= breakerThreshold {
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+	}
+}
+
+// Release ends an allowed call whose outcome says nothing about the ledger, such as one cancelled by its caller:
+// it lets another call probe a half-open circuit, without closing or opening it.
+func (b *breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// Health is the state of a ledger, see Ledger.Health.
+type Health struct {
+	Backend  string       `json:"backend"`
+	Circuit  CircuitState `json:"circuit,omitempty"`
+	Failures int          `json:"failures,omitempty"` // Consecutive failed calls
+	RetryAt  *time.Time   `json:"retryAt,omitempty"`  // When an open circuit lets a call through again
+}
+
+// Health returns the state of the circuit.
+func (b *breaker) Health() Health {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	health := Health{Circuit: b.state, Failures: b.failures}
+	if b.state == CircuitOpen {
+		retryAt := b.openedAt.Add(breakerCooldown).UTC()
+		health.RetryAt = &retryAt
+	}
+	return health
+}
@@ -0,0 +1,109 @@
+package foldpub
+
+// Standard library on top, third-party packages below.
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	// Each step runs against the same breaker, with a clock moved forward by wait before it.
+	type step struct {
+		wait   time.Duration
+		action string // allow, fail, succeed or release
+		err    error  // Of allow
+		state  CircuitState
+	}
+	fail := func(state CircuitState) step { return step{action: "fail", state: state} }
+	allowed := func(state CircuitState) step { return step{action: "allow", state: state} }
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"opens after the threshold", []step{
+			allowed(CircuitClosed), fail(CircuitClosed),
+			allowed(CircuitClosed), fail(CircuitClosed),
+			allowed(CircuitClosed), fail(CircuitClosed),
+			allowed(CircuitClosed), fail(CircuitClosed),
+			allowed(CircuitClosed), fail(CircuitOpen),
+			{action: "allow", err: ErrCircuitOpen, state: CircuitOpen},
+			{wait: breakerCooldown - time.Second, action: "allow", err: ErrCircuitOpen, state: CircuitOpen},
+		}},
+		{"a success resets the failures", []step{
+			fail(CircuitClosed), fail(CircuitClosed), fail(CircuitClosed), fail(CircuitClosed),
+			{action: "succeed", state: CircuitClosed},
+			fail(CircuitClosed),
+		}},
+		{"a single probe after the cooldown", []step{
+			fail(CircuitClosed), fail(CircuitClosed), fail(CircuitClosed), fail(CircuitClosed), fail(CircuitOpen),
+			{wait: breakerCooldown, action: "allow", state: CircuitHalfOpen},
+			{action: "allow", err: ErrCircuitOpen, state: CircuitHalfOpen},
+		}},
+		{"a successful probe closes the circuit", []step{
+			fail(CircuitClosed), fail(CircuitClosed), fail(CircuitClosed), fail(CircuitClosed), fail(CircuitOpen),
+			{wait: breakerCooldown, action: "allow", state: CircuitHalfOpen},
+			{action: "succeed", state: CircuitClosed},
+			allowed(CircuitClosed), allowed(CircuitClosed),
+		}},
+		{"a failed probe opens the circuit again", []step{
+			fail(CircuitClosed), fail(CircuitClosed), fail(CircuitClosed), fail(CircuitClosed), fail(CircuitOpen),
+			{wait: breakerCooldown, action: "allow", state: CircuitHalfOpen},
+			fail(CircuitOpen),
+			{wait: breakerCooldown - time.Second, action: "allow", err: ErrCircuitOpen, state: CircuitOpen},
+			{wait: time.Second, action: "allow", state: CircuitHalfOpen},
+		}},
+		{"a released probe lets another through", []step{
+			fail(CircuitClosed), fail(CircuitClosed), fail(CircuitClosed), fail(CircuitClosed), fail(CircuitOpen),
+			{wait: breakerCooldown, action: "allow", state: CircuitHalfOpen},
+			{action: "release", state: CircuitHalfOpen},
+			allowed(CircuitHalfOpen),
+			{action: "allow", err: ErrCircuitOpen, state: CircuitHalfOpen},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			b := newBreaker()
+			b.now = func() time.Time { return now }
+			for i, s := range test.steps {
+				now = now.Add(s.wait)
+				var err error
+				switch s.action {
+				case "allow":
+					err = b.Allow()
+				case "fail":
+					b.Done(true)
+				case "succeed":
+					b.Done(false)
+				case "release":
+					b.Release()
+				}
+				if !errors.Is(err, s.err) {
+					t.Fatalf("step %d: %s: got %v, want %v", i+1, s.action, err, s.err)
+				}
+				if health := b.Health(); health.Circuit != s.state {
+					t.Fatalf("step %d: %s: got %s, want %s", i+1, s.action, health.Circuit, s.state)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerHealth(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := newBreaker()
+	b.now = func() time.Time { return now }
+	for i := 0; i < breakerThreshold; i++ {
+		b.Done(true)
+	}
+
+	health := b.Health()
+	if health.Failures != breakerThreshold {
+		t.Errorf("got %d failures, want %d", health.Failures, breakerThreshold)
+	}
+	if want := now.Add(breakerCooldown); health.RetryAt == nil || !health.RetryAt.Equal(want) {
+		t.Errorf("got retry at %v, want %v", health.RetryAt, want)
+	}
+}
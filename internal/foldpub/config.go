@@ -0,0 +1,193 @@
+package foldpub
+
+// Standard library on top, third-party packages below.
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// FabricConfig is how to reach the SentinelVote chaincode through the Fablo REST gateway.
+// It is read from a JSON config file, then environment variables, then CLI flags, with later sources taking precedence.
+type FabricConfig struct {
+	URL                string   `json:"url"`                // Base URL of the REST gateway, e.g. http://localhost:8801
+	Username           string   `json:"username"`           // Enrolled to obtain a token
+	Password           string   `json:"password"`           // Enrolled to obtain a token
+	Channel            string   `json:"channel"`            // Channel the chaincode is deployed on
+	Chaincode          string   `json:"chaincode"`          // Name of the deployed chaincode
+	CACert             string   `json:"caCert"`             // PEM file of the CA that signed the gateway's certificate
+	InsecureSkipVerify *bool    `json:"insecureSkipVerify"` // Skip verifying the gateway's certificate, for testing only
+	Timeout            Duration `json:"timeout"`            // Of a single request to the gateway, e.g. "10s"
+	Retries            *int     `json:"retries"`            // Of an idempotent call that failed, after the first attempt
+}
+
+// Duration is a time.Duration written as a string in the config file, e.g. "10s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// DefaultFabricConfig is the network created by `fablo up` in the blockchain repository.
+func DefaultFabricConfig() FabricConfig {
+	return FabricConfig{
+		URL:       "http://localhost:8801",
+		Username:  "admin",
+		Password:  "adminpw",
+		Channel:   "vote-channel",
+		Chaincode: "SentinelVote",
+		Timeout:   Duration(10 * time.Second),
+		Retries:   &defaultRetries,
+	}
+}
+
+var defaultRetries = 3
+
+// Environment variables overriding the config file.
+const (
+	EnvFabricURL                = "SENTINELVOTE_FABRIC_URL"
+	EnvFabricUsername           = "SENTINELVOTE_FABRIC_USERNAME"
+	EnvFabricPassword           = "SENTINELVOTE_FABRIC_PASSWORD"
+	EnvFabricChannel            = "SENTINELVOTE_FABRIC_CHANNEL"
+	EnvFabricChaincode          = "SENTINELVOTE_FABRIC_CHAINCODE"
+	EnvFabricCACert             = "SENTINELVOTE_FABRIC_CA_CERT"
+	EnvFabricInsecureSkipVerify = "SENTINELVOTE_FABRIC_INSECURE_SKIP_VERIFY"
+	EnvFabricTimeout            = "SENTINELVOTE_FABRIC_TIMEOUT"
+	EnvFabricRetries            = "SENTINELVOTE_FABRIC_RETRIES"
+)
+
+// LoadFabricConfig returns the default config, overridden by the JSON file at path (if not empty),
+// and then by the environment variables above.
+func LoadFabricConfig(path string) (FabricConfig, error) {
+	config := DefaultFabricConfig()
+
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return FabricConfig{}, err
+		}
+		var file FabricConfig
+		if err := json.Unmarshal(content, &file); err != nil {
+			return FabricConfig{}, errors.New("foldpub: invalid config file " + path + ": " + err.Error())
+		}
+		config = config.Merge(file)
+	}
+
+	env := FabricConfig{
+		URL:       os.Getenv(EnvFabricURL),
+		Username:  os.Getenv(EnvFabricUsername),
+		Password:  os.Getenv(EnvFabricPassword),
+		Channel:   os.Getenv(EnvFabricChannel),
+		Chaincode: os.Getenv(EnvFabricChaincode),
+		CACert:    os.Getenv(EnvFabricCACert),
+	}
+	if value := os.Getenv(EnvFabricInsecureSkipVerify); value != "" {
+		insecure, err := strconv.ParseBool(value)
+		if err != nil {
+			return FabricConfig{}, errors.New("foldpub: invalid " + EnvFabricInsecureSkipVerify + ": " + value)
+		}
+		env.InsecureSkipVerify = &insecure
+	}
+	if value := os.Getenv(EnvFabricTimeout); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return FabricConfig{}, errors.New("foldpub: invalid " + EnvFabricTimeout + ": " + value)
+		}
+		env.Timeout = Duration(timeout)
+	}
+	if value := os.Getenv(EnvFabricRetries); value != "" {
+		retries, err := strconv.Atoi(value)
+		if err != nil || retries < 0 {
+			return FabricConfig{}, errors.New("foldpub: invalid " + EnvFabricRetries + ": " + value)
+		}
+		env.Retries = &retries
+	}
+	return config.Merge(env), nil
+}
+
+// Merge returns c with every non-empty field of override applied. A set InsecureSkipVerify is applied
+// even if false, so that a later source can turn it off again.
+func (c FabricConfig) Merge(override FabricConfig) FabricConfig {
+	if override.URL != "" {
+		c.URL = override.URL
+	}
+	if override.Username != "" {
+		c.Username = override.Username
+	}
+	if override.Password != "" {
+		c.Password = override.Password
+	}
+	if override.Channel != "" {
+		c.Channel = override.Channel
+	}
+	if override.Chaincode != "" {
+		c.Chaincode = override.Chaincode
+	}
+	if override.CACert != "" {
+		c.CACert = override.CACert
+	}
+	if override.InsecureSkipVerify != nil {
+		c.InsecureSkipVerify = override.InsecureSkipVerify
+	}
+	if override.Timeout > 0 {
+		c.Timeout = override.Timeout
+	}
+	if override.Retries != nil && *override.Retries >= 0 {
+		c.Retries = override.Retries
+	}
+	return c
+}
+
+// enrollURL, invokeURL and queryURL are the endpoints of the REST gateway.
+func (c FabricConfig) enrollURL() string {
+	return strings.TrimSuffix(c.URL, "/") + "/user/enroll"
+}
+
+func (c FabricConfig) invokeURL() string {
+	return strings.TrimSuffix(c.URL, "/") + "/invoke/" + c.Channel + "/" + c.Chaincode
+}
+
+func (c FabricConfig) queryURL() string {
+	return strings.TrimSuffix(c.URL, "/") + "/query/" + c.Channel + "/" + c.Chaincode
+}
+
+// httpClient returns a client with the configured timeout, trusting the configured CA, if any.
+func (c FabricConfig) httpClient() (*http.Client, error) {
+	insecure := c.InsecureSkipVerify != nil && *c.InsecureSkipVerify
+	if c.CACert == "" && !insecure {
+		return &http.Client{Timeout: time.Duration(c.Timeout)}, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+	if c.CACert != "" {
+		pem, err := os.ReadFile(c.CACert)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("foldpub: no certificates found in " + c.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, Timeout: time.Duration(c.Timeout)}, nil
+}
@@ -0,0 +1,309 @@
+package foldpub
+
+// Standard library on top, third-party packages below.
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/sentinelvote/backend/internal/tally"
+)
+
+type fabricUserAuth struct {
+	Id     string `json:"id"`
+	Secret string `json:"secret"`
+}
+
+type fabricUserToken struct {
+	Token string `json:"token"`
+}
+
+type fabricChaincodeRequest struct {
+	Method string   `json:"method"`
+	Args   []string `json:"args"`
+}
+
+type fabricBallot struct {
+	Message   string `json:"message"`
+	Signature string `json:"signature"`
+	TxID      string `json:"txId"`
+}
+
+// fabricResponse is the body of every reply from the REST gateway.
+// Response holds the chaincode's return value on success, and Message the error otherwise.
+type fabricResponse struct {
+	Response json.RawMessage `json:"response"`
+	Message  string          `json:"message"`
+	TxID     string          `json:"txId"`
+}
+
+// Fabric is a Ledger backed by the SentinelVote chaincode, through the Fablo REST gateway.
+// The methods of the chaincode it needs are listed in the README.
+type Fabric struct {
+	config     FabricConfig
+	httpClient *http.Client
+	tokens     *tokenManager
+	breaker    *breaker
+}
+
+var _ Ledger = (*Fabric)(nil)
+
+// NewFabric returns a Ledger talking to the Fablo REST gateway described by config.
+func NewFabric(config FabricConfig) (*Fabric, error) {
+	httpClient, err := config.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	f := &Fabric{config: config, httpClient: httpClient, breaker: newBreaker()}
+	f.tokens = newTokenManager(f.enroll)
+	return f, nil
+}
+
+// enroll enrolls the configured user to obtain a bearer token for the chaincode.
+// Use f.tokens instead, which caches the token.
+func (f *Fabric) enroll(ctx context.Context) (string, error) {
+	jsonData, err := json.Marshal(
+		fabricUserAuth{
+			Id:     f.config.Username,
+			Secret: f.config.Password,
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "POST", f.config.enrollURL(), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer")
+	response, err := f.httpClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer bodyClose(response.Body)
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("enrolling `%s` failed with status %s", f.config.Username, response.Status)
+	}
+
+	var t = fabricUserToken{}
+	err = json.NewDecoder(response.Body).Decode(&t)
+	if err != nil {
+		return "", err
+	}
+
+	return t.Token, nil
+}
+
+// chaincode calls a chaincode method through url, which is either the invoke or the query URL.
+// If the gateway rejects the cached token, the call is retried once with a new one.
+// The caller must close the response body.
+func (f *Fabric) chaincode(ctx context.Context, url string, method string, args ...string) (*http.Response, error) {
+	jsonData, err := json.Marshal(fabricChaincodeRequest{
+		Method: method,
+		Args:   args,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		token, err := f.tokens.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		request, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Authorization", "Bearer "+token)
+
+		response, err := f.httpClient.Do(request)
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode != http.StatusUnauthorized {
+			return response, nil
+		}
+		_ = response.Body.Close()
+		f.tokens.Invalidate(token)
+		if attempt > 0 {
+			return nil, fmt.Errorf("%w: `%s` is not authorized to call %s", ErrEnrollment, f.config.Username, method)
+		}
+	}
+}
+
+// call calls a chaincode method through the circuit breaker, see callOnce.
+// Idempotent methods are retried with exponential backoff while the gateway is unreachable.
+func (f *Fabric) call(ctx context.Context, url string, idempotent bool, method string, args ...string) (Result, error) {
+	retries := 0
+	if idempotent && f.config.Retries != nil {
+		retries = *f.config.Retries
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := f.breaker.Allow(); err != nil {
+			return Result{}, err
+		}
+		result, err := f.callOnce(ctx, url, method, args...)
+		if ctx.Err() != nil {
+			f.breaker.Release()
+			return result, err
+		}
+		failed := isTransient(ctx, err)
+		f.breaker.Done(failed)
+		if !failed || attempt >= retries {
+			return result, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return result, err
+		case <-time.After(backoff(attempt)):
+		}
+	}
+}
+
+// isTransient reports whether err means the gateway is unreachable or overloaded, rather than
+// the chaincode rejecting the call. Transient errors are retried and open the circuit breaker.
+func isTransient(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var chaincodeErr *ChaincodeError
+	if errors.As(err, &chaincodeErr) {
+		switch chaincodeErr.Result.Status {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var urlErr *neturl.Error
+	return errors.As(err, &urlErr)
+}
+
+// backoff returns how long to wait before the retry following attempt, with jitter.
+func backoff(attempt int) time.Duration {
+	const base, limit = 200 * time.Millisecond, 5 * time.Second
+	delay := base << attempt
+	if delay > limit || delay <= 0 {
+		delay = limit
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// Health returns the state of the circuit breaker in front of the gateway.
+func (f *Fabric) Health() Health {
+	health := f.breaker.Health()
+	health.Backend = "fabric"
+	return health
+}
+
+// callOnce calls a chaincode method and decodes the gateway's response into a Result.
+// A response other than 200 OK is returned as a *ChaincodeError, along with the Result.
+func (f *Fabric) callOnce(ctx context.Context, url string, method string, args ...string) (Result, error) {
+	response, err := f.chaincode(ctx, url, method, args...)
+	if err != nil {
+		return Result{}, err
+	}
+	defer bodyClose(response.Body)
+
+	result := Result{Status: response.StatusCode}
+	var body fabricResponse
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		result.Message = "undecodable response: " + err.Error()
+	} else {
+		result.TxID = body.TxID
+		result.Payload = body.Response
+		result.Message = body.Message
+	}
+	if response.StatusCode != http.StatusOK {
+		return result, &ChaincodeError{Method: method, Result: result}
+	}
+	return result, nil
+}
+
+// ringKey is the key of a ring in the chaincode: the election's id,
+// followed by the constituency for the ring of a single constituency, e.g. "2/PAYA LEBAR".
+func ringKey(electionID int64, constituency string) string {
+	if constituency == "" {
+		return strconv.FormatInt(electionID, 10)
+	}
+	return strconv.FormatInt(electionID, 10) + "/" + constituency
+}
+
+// PutFoldedPublicKeys stores the folded public keys in the blockchain, see ringKey.
+// It replaces any previous value, so it is safe to retry.
+func (f *Fabric) PutFoldedPublicKeys(ctx context.Context, electionID int64, constituency string, foldedPublicKeys []byte) (Result, error) {
+	return f.call(ctx, f.config.invokeURL(), true, "KVContractGo:PutFoldedPublicKeys",
+		ringKey(electionID, constituency), string(foldedPublicKeys))
+}
+
+// GetFoldedPublicKeys queries the folded public keys stored in the blockchain, see ringKey.
+func (f *Fabric) GetFoldedPublicKeys(ctx context.Context, electionID int64, constituency string) ([]byte, error) {
+	result, err := f.call(ctx, f.config.queryURL(), true, "KVContractGo:GetFoldedPublicKeys",
+		ringKey(electionID, constituency))
+	if err != nil {
+		return nil, err
+	}
+
+	var foldedPublicKeys string
+	if err := json.Unmarshal(result.Payload, &foldedPublicKeys); err != nil {
+		return nil, fmt.Errorf("fabric: unexpected folded public keys: %w", err)
+	}
+	if foldedPublicKeys == "" {
+		return nil, ErrNotPublished
+	}
+	return []byte(foldedPublicKeys), nil
+}
+
+// SubmitBallot stores a signed ballot in the blockchain under the election's id.
+// It is not retried, since a retry could record the ballot twice.
+func (f *Fabric) SubmitBallot(ctx context.Context, electionID int64, b tally.Ballot) (Result, error) {
+	return f.call(ctx, f.config.invokeURL(), false, "KVContractGo:PutBallot",
+		strconv.FormatInt(electionID, 10), string(b.Message), string(b.Signature))
+}
+
+// ListBallots queries the ballots of an election, in the order the blockchain accepted them.
+func (f *Fabric) ListBallots(ctx context.Context, electionID int64) ([]tally.Ballot, error) {
+	result, err := f.call(ctx, f.config.queryURL(), true, "KVContractGo:GetAllBallots",
+		strconv.FormatInt(electionID, 10))
+	if err != nil {
+		return nil, err
+	}
+
+	var fabricBallots []fabricBallot
+	if err := json.Unmarshal(result.Payload, &fabricBallots); err != nil {
+		return nil, fmt.Errorf("fabric: unexpected ballots: %w", err)
+	}
+	ballots := make([]tally.Ballot, 0, len(fabricBallots))
+	for _, b := range fabricBallots {
+		ballots = append(ballots, tally.Ballot{
+			Message:   []byte(b.Message),
+			Signature: []byte(b.Signature),
+			Reference: b.TxID,
+		})
+	}
+	return ballots, nil
+}
+
+// bodyClose closes a response body. It only logs an error, since the response has been read by then,
+// and ledger calls run in background jobs and the scheduler, where a panic would stop the server.
+func bodyClose(body io.ReadCloser) {
+	if err := body.Close(); err != nil {
+		log.Println("Error closing response body: " + err.Error())
+	}
+}
@@ -0,0 +1,97 @@
+package foldpub
+
+// Standard library on top, third-party packages below.
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/zbohm/lirisi/client"
+	"github.com/zbohm/lirisi/ring"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// FoldPageSize is the number of public keys FoldPublicKeysInPages reads from the database at once.
+const FoldPageSize = 10_000
+
+// CountPublicKeys returns the number of public keys FoldPublicKeys folds.
+func CountPublicKeys(conn *sqlite.Conn, electionID int64, constituency string) (int, error) {
+	var count int
+	err := sqlitex.Execute(conn, `
+		SELECT COUNT(*) FROM users u
+		JOIN election_voters v ON v.user_uuid = u.uuid
+		WHERE v.election_id = ? AND u.is_central_authority = FALSE AND u.public_key != ''
+		AND (? = '' OR u.constituency = ?);`,
+		&sqlitex.ExecOptions{
+			Args: []any{electionID, constituency, constituency},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				count = stmt.ColumnInt(0)
+				return nil
+			},
+		})
+	return count, err
+}
+
+// FoldPublicKeysInPages is FoldPublicKeys for large voter rolls.
+// It reads the public keys a page at a time, taking a connection from the pool for each page only,
+// and keeps them as DER rather than PEM, which halves the memory they take up.
+// progress, if not nil, is called after each page with the number of public keys read so far.
+//
+// lirisi folds every public key in a single call, so ctx is only checked between pages.
+func FoldPublicKeysInPages(ctx context.Context, pool *sqlitex.Pool, electionID int64, constituency string, progress func(read int)) ([]byte, error) {
+	query := `
+		SELECT u.rowid, u.public_key FROM users u
+		JOIN election_voters v ON v.user_uuid = u.uuid
+		WHERE v.election_id = ? AND u.is_central_authority = FALSE AND u.public_key != ''
+		AND (? = '' OR u.constituency = ?) AND u.rowid > ?
+		ORDER BY u.rowid LIMIT ?;`
+
+	var publicKeysContent [][]byte
+	var after int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		conn := pool.Get(ctx)
+		if conn == nil {
+			return nil, ctx.Err()
+		}
+		read := 0
+		err := sqlitex.Execute(conn, query,
+			&sqlitex.ExecOptions{
+				Args: []any{electionID, constituency, constituency, after, FoldPageSize},
+				ResultFunc: func(stmt *sqlite.Stmt) error {
+					after = stmt.ColumnInt64(0)
+					block, _ := pem.Decode([]byte(stmt.ColumnText(1)))
+					if block == nil {
+						return errors.New("foldpub: a public key is not PEM encoded")
+					}
+					publicKeysContent = append(publicKeysContent, block.Bytes)
+					read++
+					return nil
+				},
+			})
+		pool.Put(conn)
+		if err != nil {
+			return nil, err
+		}
+		if progress != nil {
+			progress(len(publicKeysContent))
+		}
+		if read < FoldPageSize {
+			break
+		}
+	}
+
+	// Fold public keys, in the same order as FoldPublicKeys.
+	status, foldedPublicKeys := client.FoldPublicKeys(publicKeysContent, "sha3-256", "PEM", "hashes")
+	if status != ring.Success {
+		return nil, fmt.Errorf("client.FoldPublicKeys() failed: status %v", status)
+	}
+	return foldedPublicKeys, nil
+}
+
+// ErrRingTooSmall is returned when a ring has fewer than MinRingSize public keys.
+var ErrRingTooSmall = errors.New("foldpub: not enough public keys to sign with")
@@ -2,91 +2,83 @@ package foldpub
 
 // Standard library on top, third-party packages below.
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 
 	"github.com/goccy/go-json"
+	"github.com/sentinelvote/backend/internal/tally"
 	"github.com/zbohm/lirisi/client"
 	"github.com/zbohm/lirisi/ring"
 	"zombiezen.com/go/sqlite"
 	"zombiezen.com/go/sqlite/sqlitex"
 )
 
-type fabricUserAuth struct {
-	Id     string `json:"id"`
-	Secret string `json:"secret"`
-}
+// Ledger is where folded public keys and ballots are published, so that anyone can audit them.
+// Fabric is the production implementation, and SQLite an embedded one for simulations.
+type Ledger interface {
+	// PutFoldedPublicKeys publishes the ring of an election, replacing any previous one.
+	// The constituency is empty for the ring of the whole election, see FoldPublicKeys.
+	PutFoldedPublicKeys(ctx context.Context, electionID int64, constituency string, foldedPublicKeys []byte) (Result, error)
 
-type fabricUserToken struct {
-	Token string `json:"token"`
-}
+	// GetFoldedPublicKeys returns the ring of an election, or ErrNotPublished.
+	GetFoldedPublicKeys(ctx context.Context, electionID int64, constituency string) ([]byte, error)
+
+	// SubmitBallot records a signed ballot.
+	SubmitBallot(ctx context.Context, electionID int64, b tally.Ballot) (Result, error)
+
+	// ListBallots returns the ballots of an election, in the order they were recorded.
+	tally.Source
 
-type fabricChaincodeRequest struct {
-	Method string   `json:"method"`
-	Args   []string `json:"args"`
+	// Health returns the state of the connection to the ledger.
+	Health() Health
 }
 
-const (
-	fabricAdminUsername = "admin"
-	fabricAdminPassword = "adminpw"
-	fabricChaincodeURL  = "http://localhost:8801/invoke/vote-channel/SentinelVote"
-	fabricUserEnrollURL = "http://localhost:8801/user/enroll"
-)
+var ErrNotPublished = errors.New("foldpub: folded public keys have not been published")
 
-func GetToken(httpClient *http.Client) (string, error) {
-	jsonData, err := json.Marshal(
-		fabricUserAuth{
-			Id:     fabricAdminUsername,
-			Secret: fabricAdminPassword,
-		},
-	)
-	if err != nil {
-		return "", err
-	}
+// Result is the outcome of a transaction on the ledger.
+type Result struct {
+	TxID    string          `json:"txId,omitempty"`    // Empty if the ledger does not report it
+	Status  int             `json:"status"`            // HTTP status of the REST gateway, 200 on success
+	Payload json.RawMessage `json:"payload,omitempty"` // Returned by the chaincode
+	Message string          `json:"message,omitempty"` // Error message, if the transaction was rejected
+}
 
-	request, err := http.NewRequest("POST", fabricUserEnrollURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", err
-	}
+// ChaincodeError is returned when the ledger rejects a transaction.
+type ChaincodeError struct {
+	Method string
+	Result Result
+}
 
-	request.Header.Set("Content-Type", "application/json")
-	request.Header.Set("Authorization", "Bearer")
-	response, err := httpClient.Do(request)
-	if err != nil {
-		return "", err
+func (e *ChaincodeError) Error() string {
+	message := e.Result.Message
+	if message == "" {
+		message = "no error message"
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			panic(err)
-		}
-	}(response.Body)
-
-	var t = fabricUserToken{}
-	err = json.NewDecoder(response.Body).Decode(&t)
-	if err != nil {
-		return "", err
-	}
-
-	return t.Token, nil
+	return fmt.Sprintf("foldpub: %s was rejected with status %d: %s", e.Method, e.Result.Status, message)
 }
 
-func PutFoldedPublicKeys(conn *sqlite.Conn) (string, error) {
+// FoldPublicKeys folds the public keys on the voter roll of an election into a ring.
+// If constituency is not empty, only the voters of that constituency are folded.
+func FoldPublicKeys(conn *sqlite.Conn, electionID int64, constituency string) ([]byte, error) {
 
 	// Get public keys.
 	var publicKeys []string
-	query := `SELECT public_key FROM users WHERE is_central_authority = FALSE AND public_key != '';`
+	query := `
+		SELECT u.public_key FROM users u
+		JOIN election_voters v ON v.user_uuid = u.uuid
+		WHERE v.election_id = ? AND u.is_central_authority = FALSE AND u.public_key != ''
+		AND (? = '' OR u.constituency = ?);`
 	err := sqlitex.Execute(conn, query,
 		&sqlitex.ExecOptions{
+			Args: []any{electionID, constituency, constituency},
 			ResultFunc: func(stmt *sqlite.Stmt) error {
 				publicKeys = append(publicKeys, stmt.ColumnText(0))
 				return nil
 			},
 		})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// Convert public keys to byte arrays.
@@ -98,35 +90,27 @@ func PutFoldedPublicKeys(conn *sqlite.Conn) (string, error) {
 	// Fold public keys.
 	status, foldedPublicKeys := client.FoldPublicKeys(publicKeysContent, "sha3-256", "PEM", "hashes")
 	if status != ring.Success {
-		return "", fmt.Errorf("client.FoldPublicKeys() failed: status %v", status)
+		return nil, fmt.Errorf("client.FoldPublicKeys() failed: status %v", status)
 	}
+	return foldedPublicKeys, nil
+}
 
-	// Send folded public keys to the blockchain.
-	httpClient := &http.Client{}
-	token, err := GetToken(httpClient)
-	jsonData, err := json.Marshal(fabricChaincodeRequest{
-		Method: "KVContractGo:PutFoldedPublicKeys",
-		Args:   []string{string(foldedPublicKeys)},
-	})
-
-	request, err := http.NewRequest("POST", fabricChaincodeURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", err
-	}
-
-	request.Header.Set("Content-Type", "application/json")
-	request.Header.Set("Authorization", "Bearer "+token)
-
-	response, err := httpClient.Do(request)
-	if err != nil {
-		return "", err
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			panic(err)
-		}
-	}(response.Body)
-
-	return "OK", nil
+// Constituencies lists the constituencies with at least one public key on the voter roll of an election,
+// i.e. the constituencies that have a ring when the election has one ring per constituency.
+func Constituencies(conn *sqlite.Conn, electionID int64) ([]string, error) {
+	var constituencies []string
+	query := `
+		SELECT DISTINCT u.constituency FROM users u
+		JOIN election_voters v ON v.user_uuid = u.uuid
+		WHERE v.election_id = ? AND u.is_central_authority = FALSE AND u.public_key != ''
+		ORDER BY u.constituency;`
+	err := sqlitex.Execute(conn, query,
+		&sqlitex.ExecOptions{
+			Args: []any{electionID},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				constituencies = append(constituencies, stmt.ColumnText(0))
+				return nil
+			},
+		})
+	return constituencies, err
 }
@@ -0,0 +1,108 @@
+package foldpub
+
+// Standard library on top, third-party packages below.
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/zbohm/lirisi/client"
+	"github.com/zbohm/lirisi/ring"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// Snapshot is a ring published to the ledger, as recorded in the ring_snapshots table.
+type Snapshot struct {
+	ID               int64     `json:"id"`
+	ElectionID       int64     `json:"electionId"`
+	Constituency     string    `json:"constituency,omitempty"` // Empty for the ring of the whole election
+	Hash             string    `json:"hash"`                   // Hex SHA-256 of FoldedPublicKeys
+	Members          int       `json:"members"`
+	FoldedPublicKeys string    `json:"foldedPublicKeys"`
+	TxID             string    `json:"txId,omitempty"`
+	RingVersion      int       `json:"ringVersion"` // The election's ring version when it was published
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+// HashFoldedPublicKeys returns the hex SHA-256 that identifies a ring.
+func HashFoldedPublicKeys(foldedPublicKeys []byte) string {
+	sum := sha256.Sum256(foldedPublicKeys)
+	return hex.EncodeToString(sum[:])
+}
+
+// MinRingSize is the fewest members a ring may have, as lirisi cannot sign with a smaller ring.
+const MinRingSize = 2
+
+// RingSize returns the number of public keys folded into a ring.
+func RingSize(foldedPublicKeys []byte) (int, error) {
+	status, publicKeys, _ := client.UnfoldPublicKeysContent(foldedPublicKeys)
+	if status != ring.Success {
+		return 0, fmt.Errorf("client.UnfoldPublicKeysContent() failed: status %v", status)
+	}
+	return len(publicKeys), nil
+}
+
+// RecordSnapshot records a ring published to the ledger under txID, as the current ring of the election
+// (or of one of its constituencies). ringVersion is the election's ring version when its public keys were read:
+// if registration has been reopened since, the snapshot is recorded but never current.
+func RecordSnapshot(conn *sqlite.Conn, electionID int64, constituency string, foldedPublicKeys []byte, txID string, ringVersion int) (Snapshot, error) {
+	members, err := RingSize(foldedPublicKeys)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	snapshot := Snapshot{
+		ElectionID:       electionID,
+		Constituency:     constituency,
+		Hash:             HashFoldedPublicKeys(foldedPublicKeys),
+		Members:          members,
+		FoldedPublicKeys: string(foldedPublicKeys),
+		TxID:             txID,
+		RingVersion:      ringVersion,
+		CreatedAt:        time.Now().UTC().Truncate(time.Second),
+	}
+	err = sqlitex.Execute(conn, `
+		INSERT INTO ring_snapshots (election_id, constituency, hash, members, folded_public_keys, tx_id, ring_version, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?);`,
+		&sqlitex.ExecOptions{
+			Args: []any{electionID, constituency, snapshot.Hash, snapshot.Members, snapshot.FoldedPublicKeys, txID, ringVersion, snapshot.CreatedAt.Unix()},
+		})
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshot.ID = conn.LastInsertRowID()
+	return snapshot, nil
+}
+
+// CurrentSnapshot returns the latest ring recorded for an election (or for one of its constituencies)
+// at the election's current ring version, and false if none was published yet.
+func CurrentSnapshot(conn *sqlite.Conn, electionID int64, constituency string) (Snapshot, bool, error) {
+	var snapshot Snapshot
+	var found bool
+	err := sqlitex.Execute(conn, `
+		SELECT s.id, s.election_id, s.constituency, s.hash, s.members, s.folded_public_keys, s.tx_id, s.ring_version, s.created_at
+		FROM ring_snapshots s
+		JOIN elections e ON e.id = s.election_id AND e.ring_version = s.ring_version
+		WHERE s.election_id = ? AND s.constituency = ? ORDER BY s.id DESC LIMIT 1;`,
+		&sqlitex.ExecOptions{
+			Args: []any{electionID, constituency},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				found = true
+				snapshot = Snapshot{
+					ID:               stmt.ColumnInt64(0),
+					ElectionID:       stmt.ColumnInt64(1),
+					Constituency:     stmt.ColumnText(2),
+					Hash:             stmt.ColumnText(3),
+					Members:          stmt.ColumnInt(4),
+					FoldedPublicKeys: stmt.ColumnText(5),
+					TxID:             stmt.ColumnText(6),
+					RingVersion:      stmt.ColumnInt(7),
+					CreatedAt:        time.Unix(stmt.ColumnInt64(8), 0).UTC(),
+				}
+				return nil
+			},
+		})
+	return snapshot, found, err
+}
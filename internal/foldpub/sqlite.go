@@ -0,0 +1,172 @@
+package foldpub
+
+// Standard library on top, third-party packages below.
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/sentinelvote/backend/internal/tally"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// SQLite is a Ledger backed by an embedded database, for running full simulations without a blockchain.
+// It keeps its own database file, so that the ledger is never mixed up with the application's state.
+type SQLite struct {
+	pool *sqlitex.Pool
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS ledger_folded_public_keys (
+    election_id        INTEGER NOT NULL,
+    constituency       TEXT    NOT NULL,
+    folded_public_keys TEXT    NOT NULL,
+    tx_id              TEXT    NOT NULL,
+    created_at         INTEGER NOT NULL DEFAULT (unixepoch()),
+    PRIMARY KEY (election_id, constituency)
+);
+CREATE TABLE IF NOT EXISTS ledger_ballots (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    election_id INTEGER NOT NULL,
+    message     TEXT    NOT NULL,
+    signature   TEXT    NOT NULL,
+    tx_id       TEXT    NOT NULL UNIQUE,
+    created_at  INTEGER NOT NULL DEFAULT (unixepoch())
+);
+CREATE INDEX IF NOT EXISTS ledger_ballots_election_id ON ledger_ballots (election_id);`
+
+var _ Ledger = (*SQLite)(nil)
+
+// NewSQLite opens the ledger database at uri, creating its tables if needed.
+func NewSQLite(uri string, poolSize int) (*SQLite, error) {
+	pool, err := sqlitex.NewPool(uri, sqlitex.PoolOptions{PoolSize: poolSize})
+	if err != nil {
+		return nil, err
+	}
+	conn := pool.Get(context.Background())
+	defer pool.Put(conn)
+	if err := sqlitex.ExecScript(conn, sqliteSchema); err != nil {
+		_ = pool.Close()
+		return nil, err
+	}
+	return &SQLite{pool: pool}, nil
+}
+
+// Close closes the ledger database.
+func (l *SQLite) Close() error {
+	return l.pool.Close()
+}
+
+// Health always reports the embedded ledger as available.
+func (l *SQLite) Health() Health {
+	return Health{Backend: "sqlite"}
+}
+
+// Reset removes every folded public key and ballot from the ledger.
+func (l *SQLite) Reset(ctx context.Context) error {
+	conn := l.pool.Get(ctx)
+	if conn == nil {
+		return ctx.Err()
+	}
+	defer l.pool.Put(conn)
+	return sqlitex.ExecScript(conn, `DELETE FROM ledger_folded_public_keys; DELETE FROM ledger_ballots;`)
+}
+
+// PutFoldedPublicKeys stores the folded public keys of an election (or of one of its constituencies),
+// replacing any previous ones.
+func (l *SQLite) PutFoldedPublicKeys(ctx context.Context, electionID int64, constituency string, foldedPublicKeys []byte) (Result, error) {
+	conn := l.pool.Get(ctx)
+	if conn == nil {
+		return Result{}, ctx.Err()
+	}
+	defer l.pool.Put(conn)
+
+	txID := uuid.NewString()
+	err := sqlitex.Execute(conn, `
+		INSERT OR REPLACE INTO ledger_folded_public_keys (election_id, constituency, folded_public_keys, tx_id)
+		VALUES (?, ?, ?, ?);`,
+		&sqlitex.ExecOptions{
+			Args: []any{electionID, constituency, string(foldedPublicKeys), txID},
+		})
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{TxID: txID, Status: http.StatusOK}, nil
+}
+
+// GetFoldedPublicKeys returns the folded public keys of an election (or of one of its constituencies),
+// or ErrNotPublished.
+func (l *SQLite) GetFoldedPublicKeys(ctx context.Context, electionID int64, constituency string) ([]byte, error) {
+	conn := l.pool.Get(ctx)
+	if conn == nil {
+		return nil, ctx.Err()
+	}
+	defer l.pool.Put(conn)
+
+	var foldedPublicKeys []byte
+	err := sqlitex.Execute(conn, `
+		SELECT folded_public_keys FROM ledger_folded_public_keys WHERE election_id = ? AND constituency = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{electionID, constituency},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				foldedPublicKeys = []byte(stmt.ColumnText(0))
+				return nil
+			},
+		})
+	if err != nil {
+		return nil, err
+	}
+	if foldedPublicKeys == nil {
+		return nil, ErrNotPublished
+	}
+	return foldedPublicKeys, nil
+}
+
+// SubmitBallot appends a signed ballot to the ledger.
+func (l *SQLite) SubmitBallot(ctx context.Context, electionID int64, b tally.Ballot) (Result, error) {
+	conn := l.pool.Get(ctx)
+	if conn == nil {
+		return Result{}, ctx.Err()
+	}
+	defer l.pool.Put(conn)
+
+	txID := uuid.NewString()
+	err := sqlitex.Execute(conn, `
+		INSERT INTO ledger_ballots (election_id, message, signature, tx_id) VALUES (?, ?, ?, ?);`,
+		&sqlitex.ExecOptions{
+			Args: []any{electionID, string(b.Message), string(b.Signature), txID},
+		})
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{TxID: txID, Status: http.StatusOK}, nil
+}
+
+// ListBallots returns the ballots of an election, in the order they were submitted.
+func (l *SQLite) ListBallots(ctx context.Context, electionID int64) ([]tally.Ballot, error) {
+	conn := l.pool.Get(ctx)
+	if conn == nil {
+		return nil, ctx.Err()
+	}
+	defer l.pool.Put(conn)
+
+	ballots := []tally.Ballot{}
+	err := sqlitex.Execute(conn, `
+		SELECT message, signature, tx_id FROM ledger_ballots WHERE election_id = ? ORDER BY id;`,
+		&sqlitex.ExecOptions{
+			Args: []any{electionID},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				ballots = append(ballots, tally.Ballot{
+					Message:   []byte(stmt.ColumnText(0)),
+					Signature: []byte(stmt.ColumnText(1)),
+					Reference: stmt.ColumnText(2),
+				})
+				return nil
+			},
+		})
+	if err != nil {
+		return nil, err
+	}
+	return ballots, nil
+}
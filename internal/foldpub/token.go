@@ -0,0 +1,130 @@
+package foldpub
+
+// Standard library on top, third-party packages below.
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+const (
+	// tokenTTL is how long a token from the REST gateway is used before enrolling again,
+	// if it is not a JWT with an expiry, see tokenExpiry.
+	// The gateway may expire tokens sooner, which is handled by invalidating them on a 401.
+	tokenTTL = 5 * time.Minute
+
+	// tokenRefreshMargin refreshes a token this long before it expires,
+	// so that it does not expire between being handed out and being used.
+	tokenRefreshMargin = 30 * time.Second
+)
+
+// ErrEnrollment is returned when a bearer token cannot be obtained from the REST gateway.
+var ErrEnrollment = errors.New("foldpub: unable to enroll with the Fabric REST gateway")
+
+// tokenManager caches the bearer token of the REST gateway, shared by every call of a Fabric ledger.
+// Concurrent callers wait for a single enrollment rather than enrolling once each,
+// without holding the lock while it is in progress.
+type tokenManager struct {
+	enroll func(ctx context.Context) (string, error)
+	now    func() time.Time
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+	enrolling *enrollment // In progress, nil if none
+}
+
+// enrollment is an enrollment in progress, whose outcome is set before done is closed.
+type enrollment struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+func newTokenManager(enroll func(ctx context.Context) (string, error)) *tokenManager {
+	return &tokenManager{enroll: enroll, now: time.Now}
+}
+
+// Token returns the cached token, enrolling again if it is missing or about to expire.
+// If another caller is already enrolling, it waits for that enrollment instead, or until ctx is done.
+func (m *tokenManager) Token(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	if m.token != "" && m.now().Add(tokenRefreshMargin).Before(m.expiresAt) {
+		token := m.token
+		m.mu.Unlock()
+		return token, nil
+	}
+	if e := m.enrolling; e != nil {
+		m.mu.Unlock()
+		select {
+		case <-e.done:
+			return e.token, e.err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	e := &enrollment{done: make(chan struct{})}
+	m.enrolling = e
+	m.mu.Unlock()
+
+	token, err := m.enroll(ctx)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrEnrollment, err)
+	} else if token == "" {
+		err = fmt.Errorf("%w: no token in the response", ErrEnrollment)
+	}
+
+	m.mu.Lock()
+	m.enrolling = nil
+	if err != nil {
+		m.token = ""
+	} else {
+		m.token = token
+		m.expiresAt = tokenExpiry(token, m.now())
+	}
+	m.mu.Unlock()
+
+	if err != nil {
+		token = ""
+	}
+	e.token, e.err = token, err
+	close(e.done)
+	return token, err
+}
+
+// tokenExpiry returns when a token expires: at its exp claim if it is a JWT with one,
+// as the gateway issues, or tokenTTL after now otherwise.
+func tokenExpiry(token string, now time.Time) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return now.Add(tokenTTL)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return now.Add(tokenTTL)
+	}
+	var claims struct {
+		ExpiresAt int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.ExpiresAt == 0 {
+		return now.Add(tokenTTL)
+	}
+	return time.Unix(claims.ExpiresAt, 0)
+}
+
+// Invalidate drops token if it is still the cached one, e.g. after the gateway rejected it.
+// A token refreshed by another caller in the meantime is kept.
+func (m *tokenManager) Invalidate(token string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.token == token {
+		m.token = ""
+	}
+}
@@ -0,0 +1,121 @@
+package session
+
+// Standard library on top, third-party packages below.
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// A session token is two base64url segments joined by a dot:
+// the JSON encoded Claims, and the HMAC-SHA256 of the first segment.
+// It is intentionally simpler than a JWT, as only this backend issues and reads them.
+
+var (
+	ErrMalformed = errors.New("session: malformed token")
+	ErrSignature = errors.New("session: invalid signature")
+	ErrExpired   = errors.New("session: token has expired")
+)
+
+// Claims is the identity carried by a session token.
+type Claims struct {
+	UUID               string `json:"sub"`
+	Email              string `json:"email"`
+	IsCentralAuthority bool   `json:"ca"`
+	IssuedAt           int64  `json:"iat"`
+	ExpiresAt          int64  `json:"exp"`
+}
+
+// Issuer mints and verifies session tokens with a shared secret.
+type Issuer struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewIssuer returns an Issuer. If secret is empty, a random secret is generated,
+// which means tokens are invalidated whenever the server restarts.
+func NewIssuer(secret string, ttl time.Duration) (*Issuer, error) {
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+	return &Issuer{secret: key, ttl: ttl}, nil
+}
+
+// Mint returns a signed token for the given user.
+func (i *Issuer) Mint(uuid string, email string, isCentralAuthority bool) (string, Claims, error) {
+	now := time.Now()
+	claims := Claims{
+		UUID:               uuid,
+		Email:              email,
+		IsCentralAuthority: isCentralAuthority,
+		IssuedAt:           now.Unix(),
+		ExpiresAt:          now.Add(i.ttl).Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", Claims{}, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(i.sign(encoded)), claims, nil
+}
+
+// Verify checks the signature and expiry of a token and returns its claims.
+func (i *Issuer) Verify(token string) (Claims, error) {
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found || encoded == "" || signature == "" {
+		return Claims{}, ErrMalformed
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return Claims{}, ErrMalformed
+	}
+	if !hmac.Equal(mac, i.sign(encoded)) {
+		return Claims{}, ErrSignature
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Claims{}, ErrMalformed
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.UUID == "" {
+		return Claims{}, ErrMalformed
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return Claims{}, ErrExpired
+	}
+	return claims, nil
+}
+
+func (i *Issuer) sign(encoded string) []byte {
+	h := hmac.New(sha256.New, i.secret)
+	h.Write([]byte(encoded))
+	return h.Sum(nil)
+}
+
+// +----------------------------------------------------------------------------------------------+
+// |                                           Context                                            |
+// +----------------------------------------------------------------------------------------------+
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the claims.
+func NewContext(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, contextKey{}, claims)
+}
+
+// FromContext returns the claims stored by NewContext, if any.
+func FromContext(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(contextKey{}).(Claims)
+	return claims, ok
+}
@@ -0,0 +1,80 @@
+package session
+
+// Standard library on top, third-party packages below.
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	issuer, err := NewIssuer("secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := issuer.Mint("0190a8c4-uuid", "user1@sentinelvote.tech", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, signature, _ := strings.Cut(token, ".")
+
+	// The same claims as token, but as a central authority, with the signature of token.
+	escalated := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(decode(t, encoded), `"ca":false`, `"ca":true`, 1)))
+	if escalated == encoded {
+		t.Fatal("the claims were not tampered with")
+	}
+
+	expiredIssuer, err := NewIssuer("secret", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _, err := expiredIssuer.Mint("0190a8c4-uuid", "user1@sentinelvote.tech", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherIssuer, err := NewIssuer("other secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSecret, _, err := otherIssuer.Mint("0190a8c4-uuid", "user1@sentinelvote.tech", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"valid", token, nil},
+		{"empty", "", ErrMalformed},
+		{"no signature", encoded, ErrMalformed},
+		{"signature not base64", encoded + ".!", ErrMalformed},
+		{"tampered claims", escalated + "." + signature, ErrSignature},
+		{"tampered signature", encoded + "." + base64.RawURLEncoding.EncodeToString([]byte("forged")), ErrSignature},
+		{"other secret", otherSecret, ErrSignature},
+		{"expired", expired, ErrExpired},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims, err := issuer.Verify(test.token)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got %v, want %v", err, test.err)
+			}
+			if err == nil && (claims.UUID != "0190a8c4-uuid" || claims.IsCentralAuthority) {
+				t.Errorf("got claims %+v", claims)
+			}
+		})
+	}
+}
+
+func decode(t *testing.T, encoded string) string {
+	t.Helper()
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(payload)
+}
@@ -0,0 +1,189 @@
+package tally
+
+// Standard library on top, third-party packages below.
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/sentinelvote/backend/internal/ballot"
+	"github.com/zbohm/lirisi/client"
+	"github.com/zbohm/lirisi/ring"
+)
+
+// Ballot is a signed ballot, as recorded by a ledger.
+type Ballot struct {
+	Message   []byte
+	Signature []byte
+	Reference string // Where the ledger recorded the ballot, e.g. a transaction id.
+}
+
+// Source lists the ballots of an election, in the order the ledger accepted them.
+type Source interface {
+	ListBallots(ctx context.Context, electionID int64) ([]Ballot, error)
+}
+
+// Policy decides which ballot counts when several ballots share a key image,
+// i.e. when a voter signed more than one ballot.
+type Policy string
+
+const (
+	FirstWins Policy = "first-wins" // The earliest ballot counts, later ones are discarded.
+	LastWins  Policy = "last-wins"  // The latest ballot counts, allowing voters to change their vote.
+)
+
+// Valid reports whether p is a known policy.
+func (p Policy) Valid() bool {
+	return p == FirstWins || p == LastWins
+}
+
+// Outcome describes what happened to a ballot during counting.
+type Outcome string
+
+const (
+	Counted   Outcome = "counted"
+	Invalid   Outcome = "invalid"   // The signature does not verify against the ring.
+	Malformed Outcome = "malformed" // The message is not an eligible ballot, see ballot.Decode.
+	Duplicate Outcome = "duplicate" // Linked to a ballot that counted instead.
+)
+
+// Options describe the election being counted.
+type Options struct {
+	FoldedPublicKeys []byte
+	CaseIdentifier   []byte
+	Policy           Policy
+
+	// Rings are the folded public keys of each constituency, for elections with one ring per constituency.
+	// If set, a ballot is verified against the ring of the constituency it names, and FoldedPublicKeys is unused.
+	Rings map[string][]byte
+
+	// Eligible reports whether a well-formed ballot may be counted,
+	// see ballot.Eligible. Nil accepts every well-formed ballot.
+	Eligible func(ballot.Ballot) bool
+}
+
+// BallotResult is the outcome of a single ballot.
+type BallotResult struct {
+	Reference string  `json:"reference"`
+	KeyImage  string  `json:"keyImage"`
+	Outcome   Outcome `json:"outcome"`
+}
+
+// Result holds the totals of a count.
+type Result struct {
+	Policy         Policy                   `json:"policy"`
+	Total          int                      `json:"total"`
+	Counted        int                      `json:"counted"`
+	Invalid        int                      `json:"invalid"`
+	Duplicates     int                      `json:"duplicates"`
+	Candidates     map[int64]int            `json:"candidates"`     // Votes per candidate id.
+	Constituencies map[string]map[int64]int `json:"constituencies"` // Votes per candidate id, per constituency, see Count.
+	Ballots        []BallotResult           `json:"-"`
+
+	// ConstituenciesVerified is whether each ballot was verified against the ring of the constituency it names.
+	// If not, Constituencies rests on the constituency each ballot claims.
+	ConstituenciesVerified bool `json:"constituenciesVerified"`
+}
+
+var ErrUnknownPolicy = errors.New("tally: unknown policy")
+
+// Count verifies every ballot against the folded public keys, discards linked duplicates
+// according to the policy, and totals the remaining ballots per candidate and per constituency.
+// Without a ring per constituency, a voter of any constituency can sign a ballot naming another one
+// with the election's ring, so the totals per constituency are not verified, see Result.
+func Count(ballots []Ballot, opts Options) (Result, error) {
+	policy := opts.Policy
+	if !policy.Valid() {
+		return Result{}, ErrUnknownPolicy
+	}
+
+	// Unfold each ring once, rather than once per ballot.
+	rings := map[string][]*ecdsa.PublicKey{}
+	if opts.Rings == nil {
+		status, publicKeys, _ := client.UnfoldPublicKeysContent(opts.FoldedPublicKeys)
+		if status != ring.Success {
+			return Result{}, fmt.Errorf("tally: unable to unfold public keys: %s", ring.ErrorMessages[status])
+		}
+		rings[""] = publicKeys
+	}
+	for constituency, foldedPublicKeys := range opts.Rings {
+		status, publicKeys, _ := client.UnfoldPublicKeysContent(foldedPublicKeys)
+		if status != ring.Success {
+			return Result{}, fmt.Errorf("tally: unable to unfold public keys of %s: %s", constituency, ring.ErrorMessages[status])
+		}
+		rings[constituency] = publicKeys
+	}
+
+	result := Result{
+		Policy:         policy,
+		Total:          len(ballots),
+		Candidates:     map[int64]int{},
+		Constituencies: map[string]map[int64]int{},
+		Ballots:        make([]BallotResult, len(ballots)),
+
+		ConstituenciesVerified: opts.Rings != nil,
+	}
+
+	// Verify signatures, and remember which ballot wins for each key image.
+	choices := make([]ballot.Ballot, len(ballots))
+	winners := map[string]int{}
+	for i, b := range ballots {
+		result.Ballots[i].Reference = b.Reference
+
+		// With a ring per constituency, the ballot names the ring it was signed with.
+		publicKeys := rings[""]
+		if opts.Rings != nil {
+			choice, err := ballot.Decode(b.Message)
+			if err != nil {
+				result.Ballots[i].Outcome = Malformed
+				continue
+			}
+			publicKeys = rings[choice.Constituency]
+		}
+
+		status, signature := client.ParseSignature(b.Signature)
+		if status != ring.Success || publicKeys == nil || ring.Verify(&signature, publicKeys, b.Message, opts.CaseIdentifier) != ring.Success {
+			result.Ballots[i].Outcome = Invalid
+			continue
+		}
+		keyImage := hex.EncodeToString(signature.KeyImage.Bytes())
+		result.Ballots[i].KeyImage = keyImage
+
+		choice, err := ballot.Decode(b.Message)
+		if err != nil || (opts.Eligible != nil && !opts.Eligible(choice)) {
+			result.Ballots[i].Outcome = Malformed
+			continue
+		}
+		choices[i] = choice
+
+		if _, seen := winners[keyImage]; !seen || policy == LastWins {
+			winners[keyImage] = i
+		}
+		result.Ballots[i].Outcome = Duplicate
+	}
+
+	// Total the winning ballots.
+	for _, i := range winners {
+		result.Ballots[i].Outcome = Counted
+		choice := choices[i]
+		result.Candidates[choice.Candidate]++
+		if result.Constituencies[choice.Constituency] == nil {
+			result.Constituencies[choice.Constituency] = map[int64]int{}
+		}
+		result.Constituencies[choice.Constituency][choice.Candidate]++
+	}
+	for _, b := range result.Ballots {
+		switch b.Outcome {
+		case Counted:
+			result.Counted++
+		case Duplicate:
+			result.Duplicates++
+		default:
+			result.Invalid++
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,140 @@
+package tally
+
+// Standard library on top, application and third-party packages below.
+import (
+	"errors"
+	"testing"
+
+	"github.com/sentinelvote/backend/internal/ballot"
+	"github.com/zbohm/lirisi/client"
+	"github.com/zbohm/lirisi/ring"
+)
+
+var caseIdentifier = []byte("sentinelvote/election/1")
+
+// newVoters returns the private keys of n voters.
+func newVoters(t *testing.T, n int) [][]byte {
+	t.Helper()
+	privateKeys := make([][]byte, n)
+	for i := range privateKeys {
+		status, privateKey := client.GeneratePrivateKey("prime256v1", "PEM")
+		if status != ring.Success {
+			t.Fatalf("GeneratePrivateKey: %s", ring.ErrorMessages[status])
+		}
+		privateKeys[i] = privateKey
+	}
+	return privateKeys
+}
+
+// fold returns the folded public keys of voters, as foldpub.FoldPublicKeys does.
+func fold(t *testing.T, privateKeys ...[]byte) []byte {
+	t.Helper()
+	publicKeys := make([][]byte, len(privateKeys))
+	for i, privateKey := range privateKeys {
+		status, publicKey := client.DerivePublicKey(privateKey, "PEM")
+		if status != ring.Success {
+			t.Fatalf("DerivePublicKey: %s", ring.ErrorMessages[status])
+		}
+		publicKeys[i] = publicKey
+	}
+	status, foldedPublicKeys := client.FoldPublicKeys(publicKeys, "sha3-256", "PEM", "hashes")
+	if status != ring.Success {
+		t.Fatalf("FoldPublicKeys: %s", ring.ErrorMessages[status])
+	}
+	return foldedPublicKeys
+}
+
+// sign returns a ballot with message, signed by privateKey with the ring foldedPublicKeys.
+func sign(t *testing.T, foldedPublicKeys []byte, privateKey []byte, message []byte, reference string) Ballot {
+	t.Helper()
+	status, signature := client.CreateSignature(foldedPublicKeys, privateKey, message, caseIdentifier, "PEM")
+	if status != ring.Success {
+		t.Fatalf("CreateSignature: %s", ring.ErrorMessages[status])
+	}
+	return Ballot{Message: message, Signature: signature, Reference: reference}
+}
+
+func TestCount(t *testing.T) {
+	// Voters 0 to 2 are in the ring, voter 3 signs with another ring.
+	privateKeys := newVoters(t, 4)
+	foldedPublicKeys := fold(t, privateKeys[0], privateKeys[1], privateKeys[2])
+	otherRing := fold(t, privateKeys[1], privateKeys[3])
+	vote := func(candidate int64) []byte {
+		return ballot.Ballot{Election: 1, Constituency: "BEDOK", Candidate: candidate}.Encode()
+	}
+
+	tampered := sign(t, foldedPublicKeys, privateKeys[2], vote(1), "tampered")
+	tampered.Message = vote(2)
+	ballots := []Ballot{
+		sign(t, foldedPublicKeys, privateKeys[0], vote(1), "first"),
+		sign(t, foldedPublicKeys, privateKeys[1], vote(2), "other"),
+		sign(t, foldedPublicKeys, privateKeys[0], vote(2), "last"),
+		sign(t, otherRing, privateKeys[3], vote(1), "outsider"),
+		tampered,
+		sign(t, foldedPublicKeys, privateKeys[2], []byte(`{"election":1, "constituency":"BEDOK","candidate":1}`), "malformed"),
+	}
+
+	tests := []struct {
+		policy     Policy
+		outcomes   map[string]Outcome
+		candidates map[int64]int
+	}{
+		{
+			policy: FirstWins,
+			outcomes: map[string]Outcome{
+				"first": Counted, "other": Counted, "last": Duplicate,
+				"outsider": Invalid, "tampered": Invalid, "malformed": Malformed,
+			},
+			candidates: map[int64]int{1: 1, 2: 1},
+		},
+		{
+			policy: LastWins,
+			outcomes: map[string]Outcome{
+				"first": Duplicate, "other": Counted, "last": Counted,
+				"outsider": Invalid, "tampered": Invalid, "malformed": Malformed,
+			},
+			candidates: map[int64]int{2: 2},
+		},
+	}
+	for _, test := range tests {
+		t.Run(string(test.policy), func(t *testing.T) {
+			result, err := Count(ballots, Options{
+				FoldedPublicKeys: foldedPublicKeys,
+				CaseIdentifier:   caseIdentifier,
+				Policy:           test.policy,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, b := range result.Ballots {
+				if b.Outcome != test.outcomes[b.Reference] {
+					t.Errorf("ballot %s: got %s, want %s", b.Reference, b.Outcome, test.outcomes[b.Reference])
+				}
+			}
+			if result.Total != 6 || result.Counted != 2 || result.Duplicates != 1 || result.Invalid != 3 {
+				t.Errorf("got total %d, counted %d, duplicates %d, invalid %d, want 6, 2, 1, 3",
+					result.Total, result.Counted, result.Duplicates, result.Invalid)
+			}
+			if len(result.Candidates) != len(test.candidates) {
+				t.Errorf("got candidates %v, want %v", result.Candidates, test.candidates)
+			}
+			for candidate, votes := range test.candidates {
+				if result.Candidates[candidate] != votes {
+					t.Errorf("got candidates %v, want %v", result.Candidates, test.candidates)
+				}
+			}
+			if len(result.Constituencies) != 1 || len(result.Constituencies["BEDOK"]) != len(test.candidates) {
+				t.Errorf("got constituencies %v, want BEDOK with %v", result.Constituencies, test.candidates)
+			}
+			if result.ConstituenciesVerified {
+				t.Error("got constituencies verified with a single ring")
+			}
+		})
+	}
+}
+
+func TestCountUnknownPolicy(t *testing.T) {
+	if _, err := Count(nil, Options{Policy: "most-wins"}); !errors.Is(err, ErrUnknownPolicy) {
+		t.Errorf("got %v, want ErrUnknownPolicy", err)
+	}
+}
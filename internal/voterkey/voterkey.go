@@ -0,0 +1,178 @@
+package voterkey
+
+// Standard library on top, third-party packages below.
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"time"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// ChallengeTTL is how long a voter has to sign a challenge.
+const ChallengeTTL = 5 * time.Minute
+
+var (
+	ErrInvalidPublicKey = errors.New("voterkey: invalid public key")
+	ErrWrongCurve       = errors.New("voterkey: public key is not on the prime256v1 curve")
+	ErrInvalidProof     = errors.New("voterkey: invalid proof of possession")
+	ErrChallenge        = errors.New("voterkey: unknown, used or expired challenge")
+)
+
+var (
+	oidPublicKeyECDSA = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidCurvePrime256  = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+)
+
+// A voter's key pair is generated on their device, and they prove possession of the private key by signing
+// a single-use challenge with it, see IssueChallenge and VerifyProof. Only the public key reaches the server.
+
+// Challenge is a nonce for a voter to sign, see ProofMessage.
+type Challenge struct {
+	Nonce     string    `json:"challenge"`
+	Message   string    `json:"message"` // The exact message to sign
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// ProofMessage is the message a voter signs to prove possession of their private key.
+// It names the voter and a challenge, so that a proof can neither be used for another voter nor replayed.
+func ProofMessage(voterUUID string, nonce string) []byte {
+	return []byte("SentinelVote proof of possession for voter " + voterUUID + " with challenge " + nonce)
+}
+
+// IssueChallenge stores a new challenge for a voter, valid for ChallengeTTL.
+// Expired challenges of every voter are removed along the way.
+func IssueChallenge(conn *sqlite.Conn, voterUUID string) (challenge Challenge, err error) {
+	defer sqlitex.Save(conn)(&err)
+
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return Challenge{}, err
+	}
+	challenge.Nonce = base64.RawURLEncoding.EncodeToString(nonce)
+	challenge.Message = string(ProofMessage(voterUUID, challenge.Nonce))
+	challenge.ExpiresAt = time.Now().UTC().Add(ChallengeTTL).Truncate(time.Second)
+
+	if err := sqlitex.Execute(conn, `DELETE FROM key_challenges WHERE expires_at <= unixepoch();`, nil); err != nil {
+		return Challenge{}, err
+	}
+	err = sqlitex.Execute(conn, `INSERT INTO key_challenges (nonce, user_uuid, expires_at) VALUES (?, ?, ?);`,
+		&sqlitex.ExecOptions{
+			Args: []any{challenge.Nonce, voterUUID, challenge.ExpiresAt.Unix()},
+		})
+	return challenge, err
+}
+
+// ConsumeChallenge removes a challenge issued to a voter, so that it is only used once.
+// It returns ErrChallenge if the voter has no such challenge, or if it has expired.
+func ConsumeChallenge(conn *sqlite.Conn, voterUUID string, nonce string) error {
+	err := sqlitex.Execute(conn, `
+		DELETE FROM key_challenges WHERE nonce = ? AND user_uuid = ? AND expires_at > unixepoch();`,
+		&sqlitex.ExecOptions{
+			Args: []any{nonce, voterUUID},
+		})
+	if err != nil {
+		return err
+	}
+	if conn.Changes() == 0 {
+		return ErrChallenge
+	}
+	return nil
+}
+
+// InUse reports whether a voter other than voterUUID has registered the public key, encoded with EncodePublicKey.
+// A ring with the same key twice would let either voter sign for both.
+func InUse(conn *sqlite.Conn, publicKeyPEM string, voterUUID string) (bool, error) {
+	var inUse bool
+	err := sqlitex.Execute(conn, `SELECT EXISTS (SELECT 1 FROM users WHERE public_key = ? AND uuid != ?);`,
+		&sqlitex.ExecOptions{
+			Args: []any{publicKeyPEM, voterUUID},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				inUse = stmt.ColumnBool(0)
+				return nil
+			},
+		})
+	return inUse, err
+}
+
+// ParsePublicKey parses a single PEM encoded prime256v1 (P-256) public key, the curve rings are folded with.
+func ParsePublicKey(publicKeyPEM string) (*ecdsa.PublicKey, error) {
+	block, rest := pem.Decode([]byte(publicKeyPEM))
+	if block == nil || block.Type != "PUBLIC KEY" || len(bytes.TrimSpace(rest)) != 0 {
+		return nil, ErrInvalidPublicKey
+	}
+
+	// Check the curve first, as x509 reports curves it does not support (e.g. secp256k1) as malformed keys.
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if rest, err := asn1.Unmarshal(block.Bytes, &spki); err != nil || len(rest) != 0 {
+		return nil, ErrInvalidPublicKey
+	}
+	if !spki.Algorithm.Algorithm.Equal(oidPublicKeyECDSA) {
+		return nil, ErrInvalidPublicKey
+	}
+	var curve asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(spki.Algorithm.Parameters.FullBytes, &curve); err != nil || !curve.Equal(oidCurvePrime256) {
+		return nil, ErrWrongCurve
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidPublicKey, err)
+	}
+	publicKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, ErrInvalidPublicKey
+	}
+	return publicKey, nil
+}
+
+// EncodePublicKey returns the PEM encoding of a public key, in the same format as lirisi.
+// Public keys are stored in this form, so that a key registered twice is stored identically.
+func EncodePublicKey(publicKey *ecdsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// MatchesPrivateKey reports whether a PEM encoded private key, as generated by lirisi, belongs to publicKey.
+// Simulations, which send private keys to the server, use it instead of a signed challenge.
+func MatchesPrivateKey(publicKey *ecdsa.PublicKey, privateKeyPEM string) bool {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return false
+	}
+	privateKey, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return false
+	}
+	return publicKey.Equal(&privateKey.PublicKey)
+}
+
+// VerifyProof checks that proof is a signature of message by the private key of publicKey:
+// an ECDSA signature over the SHA-256 of message, ASN.1 DER encoded then base64 encoded,
+// as produced by `openssl dgst -sha256 -sign key.pem | base64`.
+func VerifyProof(publicKey *ecdsa.PublicKey, message []byte, proof string) error {
+	signature, err := base64.StdEncoding.DecodeString(proof)
+	if err != nil {
+		return ErrInvalidProof
+	}
+	digest := sha256.Sum256(message)
+	if !ecdsa.VerifyASN1(publicKey, digest[:], signature) {
+		return ErrInvalidProof
+	}
+	return nil
+}
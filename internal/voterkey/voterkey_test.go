@@ -0,0 +1,127 @@
+package voterkey
+
+// Standard library on top, third-party packages below.
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"testing"
+)
+
+// encodePEM returns the PEM encoding of a public key in DER.
+func encodePEM(der []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func newKey(t *testing.T, curve elliptic.Curve) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestParsePublicKey(t *testing.T) {
+	key := newKey(t, elliptic.P256())
+	valid, err := EncodePublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := x509.MarshalPKIXPublicKey(&newKey(t, elliptic.P384()).PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// x509 cannot marshal secp256k1 keys, the curve of Ethereum and Bitcoin keys, so only the header is real.
+	secp256k1, err := asn1.Marshal(struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}{
+		Algorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPublicKeyECDSA,
+			Parameters: asn1.RawValue{FullBytes: mustMarshal(t, asn1.ObjectIdentifier{1, 3, 132, 0, 10})},
+		},
+		PublicKey: asn1.BitString{Bytes: make([]byte, 65), BitLength: 65 * 8},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		pem  string
+		err  error
+	}{
+		{"valid", valid, nil},
+		{"valid with trailing newlines", valid + "\n\n", nil},
+		{"empty", "", ErrInvalidPublicKey},
+		{"not PEM", "prime256v1", ErrInvalidPublicKey},
+		{"trailing PEM block", valid + valid, ErrInvalidPublicKey},
+		{"trailing text", valid + "garbage", ErrInvalidPublicKey},
+		{"private key", string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: []byte{0}})), ErrInvalidPublicKey},
+		{"P-384", encodePEM(p384), ErrWrongCurve},
+		{"secp256k1", encodePEM(secp256k1), ErrWrongCurve},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			publicKey, err := ParsePublicKey(test.pem)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got %v, want %v", err, test.err)
+			}
+			if err == nil && !publicKey.Equal(&key.PublicKey) {
+				t.Error("got another public key")
+			}
+		})
+	}
+}
+
+func TestVerifyProof(t *testing.T) {
+	key := newKey(t, elliptic.P256())
+	message := ProofMessage("0190a8c4-uuid", "nonce")
+	prove := func(key *ecdsa.PrivateKey, message []byte) string {
+		digest := sha256.Sum256(message)
+		signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.StdEncoding.EncodeToString(signature)
+	}
+
+	tests := []struct {
+		name  string
+		proof string
+		err   error
+	}{
+		{"valid", prove(key, message), nil},
+		{"empty", "", ErrInvalidProof},
+		{"not base64", "not base64!", ErrInvalidProof},
+		{"not a signature", base64.StdEncoding.EncodeToString([]byte("signature")), ErrInvalidProof},
+		{"other key", prove(newKey(t, elliptic.P256()), message), ErrInvalidProof},
+		{"other voter", prove(key, ProofMessage("0190a8c5-uuid", "nonce")), ErrInvalidProof},
+		{"other challenge", prove(key, ProofMessage("0190a8c4-uuid", "other nonce")), ErrInvalidProof},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := VerifyProof(&key.PublicKey, message, test.proof); !errors.Is(err, test.err) {
+				t.Errorf("got %v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, value any) []byte {
+	t.Helper()
+	encoded, err := asn1.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}